@@ -0,0 +1,219 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// DefaultBreakerCooldown is how long the circuit breaker stays open before probing,
+// unless Config.BreakerCooldown is set.
+var DefaultBreakerCooldown = 30 * time.Second
+
+type BreakerState int
+
+const (
+	// BreakerClosed lets requests through.
+	BreakerClosed BreakerState = iota
+
+	// BreakerOpen fails requests with ErrInstanceUnavailable until the cooldown passed.
+	BreakerOpen
+
+	// BreakerHalfOpen is probing whether the instance is back; other requests fail
+	// meanwhile.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Health is the state of the connection to the instance as seen by the client.
+type Health struct {
+	State BreakerState
+
+	// ConsecutiveFailures counts transport failures since the last response.
+	ConsecutiveFailures int
+
+	LastError     error
+	LastErrorTime time.Time
+
+	// LastSuccess is when the last response was received, of any status.
+	LastSuccess time.Time
+
+	// Latency is a moving average of the time to a response.
+	Latency time.Duration
+
+	// breaker is whether the circuit breaker is enabled.
+	breaker bool
+}
+
+// Healthy reports whether requests are let through. With the breaker disabled, it
+// reports whether the last request got a response instead.
+func (h Health) Healthy() bool {
+	if !h.breaker {
+		return h.ConsecutiveFailures == 0
+	}
+
+	return h.State == BreakerClosed
+}
+
+// breaker opens after consecutive transport failures, so requests to an instance that is
+// down fail fast instead of each spending the timeout on retries. A disabled breaker
+// never opens and only keeps track of the health.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	health   Health
+	openedAt time.Time
+}
+
+// newBreaker returns a disabled breaker unless threshold is positive.
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		return &breaker{}
+	}
+
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+
+	return &breaker{threshold: threshold, cooldown: cooldown, health: Health{breaker: true}}
+}
+
+// allow returns ErrInstanceUnavailable while the breaker is open. Once the cooldown
+// passed, the first caller probes the instance with probe and the breaker closes if it
+// succeeds.
+func (b *breaker) allow(ctx context.Context, probe func(context.Context) error) error {
+	b.mu.Lock()
+
+	switch b.health.State {
+	case BreakerClosed:
+		b.mu.Unlock()
+		return nil
+	case BreakerHalfOpen:
+		b.mu.Unlock()
+		return ErrInstanceUnavailable
+	}
+
+	if time.Since(b.openedAt) < b.cooldown {
+		b.mu.Unlock()
+		return ErrInstanceUnavailable
+	}
+
+	b.health.State = BreakerHalfOpen
+	b.mu.Unlock()
+
+	start := time.Now()
+	err := probe(ctx)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err != nil && ctx.Err() != nil {
+		// probe again on the next request
+		b.health.State = BreakerOpen
+		return errors.Wrap(ErrInstanceUnavailable, "probe canceled: %v", err)
+	}
+
+	if err != nil {
+		b.health.State = BreakerOpen
+		b.openedAt = time.Now()
+		b.setError(err)
+
+		return errors.Wrap(ErrInstanceUnavailable, "probe failed: %v", err)
+	}
+
+	b.health.State = BreakerClosed
+	b.health.ConsecutiveFailures = 0
+	b.success(time.Since(start))
+
+	return nil
+}
+
+// record updates the breaker with the outcome of a request. err is the transport error,
+// a response of any status counts as success.
+func (b *breaker) record(ctx context.Context, d time.Duration, err error) {
+	// giving up is not the instance's fault
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		b.health.ConsecutiveFailures = 0
+		b.success(d)
+		return
+	}
+
+	b.health.ConsecutiveFailures++
+	b.setError(err)
+
+	if b.threshold > 0 && b.health.State == BreakerClosed && b.health.ConsecutiveFailures >= b.threshold {
+		b.health.State = BreakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+func (b *breaker) success(d time.Duration) {
+	b.health.LastSuccess = time.Now()
+
+	if b.health.Latency == 0 {
+		b.health.Latency = d
+	} else {
+		b.health.Latency = (4*b.health.Latency + d) / 5
+	}
+}
+
+func (b *breaker) setError(err error) {
+	b.health.LastError = err
+	b.health.LastErrorTime = time.Now()
+}
+
+// Health returns the state of the circuit breaker and the latest request outcomes. With
+// the breaker disabled the state is always BreakerClosed, the outcomes are recorded all
+// the same.
+func (c *Client) Health() Health {
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+
+	return c.breaker.health
+}
+
+// probe checks whether the instance answers at all. Any status will do, the session may
+// well have expired while the instance was down.
+func (c *Client) probe(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.buildUrl("app/version", nil), nil)
+	if err != nil {
+		return errors.Wrap(err, "could not build request")
+	}
+
+	if c.cfg.BasicUser != "" && c.cfg.BasicPass != "" {
+		req.SetBasicAuth(c.cfg.BasicUser, c.cfg.BasicPass)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	return nil
+}
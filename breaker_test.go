@@ -0,0 +1,159 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+func TestClient_Breaker(t *testing.T) {
+	var (
+		down  atomic.Bool
+		sent  atomic.Int32
+		probe atomic.Int32
+	)
+
+	client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("[]"))
+		},
+		"app/version": func(w http.ResponseWriter, r *http.Request) {
+			probe.Add(1)
+			_, _ = w.Write([]byte("v5.0.0"))
+		},
+	})
+
+	client.breaker = newBreaker(2, time.Minute)
+	client.WithHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent.Add(1)
+		if down.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+
+	ctx := context.Background()
+
+	_, err := client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, client.Health().State)
+	assert.Greater(t, client.Health().Latency, time.Duration(0))
+
+	// the retries stop once the breaker opened
+	down.Store(true)
+	sent.Store(0)
+
+	_, err = client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.ErrorIs(t, err, ErrInstanceUnavailable)
+	assert.EqualValues(t, 2, sent.Load())
+
+	health := client.Health()
+	assert.Equal(t, BreakerOpen, health.State)
+	assert.False(t, health.Healthy())
+	assert.Equal(t, 2, health.ConsecutiveFailures)
+	assert.ErrorContains(t, health.LastError, "connection refused")
+
+	// fails fast during the cooldown
+	_, err = client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.ErrorIs(t, err, ErrInstanceUnavailable)
+	assert.EqualValues(t, 2, sent.Load())
+
+	// a failed probe keeps it open
+	client.breaker.openedAt = time.Now().Add(-time.Minute)
+
+	_, err = client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.ErrorIs(t, err, ErrInstanceUnavailable)
+	assert.EqualValues(t, 3, sent.Load())
+	assert.Equal(t, BreakerOpen, client.Health().State)
+
+	// a successful probe closes it
+	down.Store(false)
+	client.breaker.openedAt = time.Now().Add(-time.Minute)
+
+	_, err = client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, probe.Load())
+
+	health = client.Health()
+	assert.Equal(t, BreakerClosed, health.State)
+	assert.Equal(t, 0, health.ConsecutiveFailures)
+}
+
+func TestClient_Breaker_StatusIsNotFailure(t *testing.T) {
+	client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	})
+
+	client.breaker = newBreaker(1, time.Minute)
+
+	_, err := client.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrInstanceUnavailable)
+	assert.Equal(t, BreakerClosed, client.Health().State)
+}
+
+func TestClient_Breaker_Disabled(t *testing.T) {
+	var down atomic.Bool
+
+	client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+		"auth/login": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("Ok."))
+		},
+	})
+	client.WithHTTPClient(&http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if down.Load() {
+			return nil, errors.New("connection refused")
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})})
+	client.cfg.Username = "admin"
+
+	ctx := context.Background()
+	assert.True(t, client.Health().Healthy())
+
+	// the outcomes are recorded without the breaker as well, so Pool.Route can skip
+	// the instance
+	down.Store(true)
+	assert.Error(t, client.LoginCtx(ctx))
+
+	health := client.Health()
+	assert.Equal(t, BreakerClosed, health.State)
+	assert.False(t, health.Healthy())
+	assert.ErrorContains(t, health.LastError, "connection refused")
+
+	down.Store(false)
+	assert.NoError(t, client.LoginCtx(ctx))
+
+	health = client.Health()
+	assert.True(t, health.Healthy())
+	assert.Greater(t, health.Latency, time.Duration(0))
+}
+
+func TestClient_ReloginFailed(t *testing.T) {
+	var logins atomic.Int32
+
+	client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		},
+		"auth/login": func(w http.ResponseWriter, r *http.Request) {
+			logins.Add(1)
+			_, _ = w.Write([]byte("Fails."))
+		},
+	})
+	client.cfg.Username = "admin"
+	client.setCookies([]*http.Cookie{{Name: "SID", Value: "expired"}})
+
+	// retrying does not fix the credentials
+	_, err := client.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+	assert.ErrorIs(t, err, ErrLoginFailed)
+	assert.Equal(t, int32(1), logins.Load())
+}
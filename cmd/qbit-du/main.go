@@ -0,0 +1,172 @@
+// Command qbit-du reports the disk usage of qBittorrent torrents by category, tag or
+// tracker, telling apart data that is hard linked from elsewhere, e.g. a media library.
+// It must run where the torrent data is mounted.
+//
+//	qbit-du -host http://localhost:8080 -username admin -group tracker
+//	qbit-du -path-map /downloads=/mnt/torrents -safe
+//
+// The password is read from the QBIT_PASSWORD environment variable unless -password is
+// given.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+// pathMap collects repeated -path-map flags.
+type pathMap map[string]string
+
+func (m pathMap) String() string {
+	var s []string
+	for k, v := range m {
+		s = append(s, k+"="+v)
+	}
+	return strings.Join(s, ",")
+}
+
+func (m pathMap) Set(s string) error {
+	remote, local, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("expected remote=local, got %q", s)
+	}
+
+	m[remote] = local
+	return nil
+}
+
+func main() {
+	var (
+		host       = flag.String("host", "http://localhost:8080", "qBittorrent WebUI url")
+		username   = flag.String("username", "", "qBittorrent username")
+		password   = flag.String("password", os.Getenv("QBIT_PASSWORD"), "qBittorrent password, defaults to $QBIT_PASSWORD")
+		basicUser  = flag.String("basic-user", "", "HTTP basic auth username")
+		basicPass  = flag.String("basic-pass", os.Getenv("QBIT_BASIC_PASSWORD"), "HTTP basic auth password, defaults to $QBIT_BASIC_PASSWORD")
+		skipVerify = flag.Bool("tls-skip-verify", false, "skip TLS certificate verification")
+		group      = flag.String("group", "category", "group usage by category, tag or tracker")
+		safe       = flag.Bool("safe", false, "list the torrents that are safe to delete instead")
+		asJSON     = flag.Bool("json", false, "print the full report as JSON")
+		paths      = pathMap{}
+	)
+
+	flag.Var(paths, "path-map", "map a path prefix as qBittorrent reports it to a local path, remote=local, repeatable")
+	flag.Parse()
+
+	client := qbittorrent.NewClient(qbittorrent.Config{
+		Host:          *host,
+		Username:      *username,
+		Password:      *password,
+		BasicUser:     *basicUser,
+		BasicPass:     *basicPass,
+		TLSSkipVerify: *skipVerify,
+	})
+
+	ctx := context.Background()
+
+	if err := client.LoginCtx(ctx); err != nil {
+		log.Fatalf("could not log into qBittorrent: %q", err)
+	}
+
+	report, err := client.DiskUsageCtx(ctx, qbittorrent.DiskUsageOptions{PathMap: paths})
+	if err != nil {
+		log.Fatalf("could not get disk usage: %q", err)
+	}
+
+	switch {
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			log.Fatalf("could not write report: %q", err)
+		}
+	case *safe:
+		printSafe(report)
+	default:
+		groups, err := groupBy(report, *group)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printGroups(*group, groups, report.Total)
+	}
+}
+
+func groupBy(report *qbittorrent.DiskUsageReport, group string) (map[string]qbittorrent.DiskUsage, error) {
+	switch group {
+	case "category":
+		return report.ByCategory, nil
+	case "tag":
+		return report.ByTag, nil
+	case "tracker":
+		return report.ByTracker, nil
+	default:
+		return nil, fmt.Errorf("unknown group %q, expected category, tag or tracker", group)
+	}
+}
+
+func printGroups(group string, groups map[string]qbittorrent.DiskUsage, total qbittorrent.DiskUsage) {
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+
+	// largest first
+	sort.Slice(keys, func(i, j int) bool {
+		if groups[keys[i]].Size != groups[keys[j]].Size {
+			return groups[keys[i]].Size > groups[keys[j]].Size
+		}
+		return keys[i] < keys[j]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "%s\ttorrents\tsize\tlinked\tunlinked\t\n", strings.ToUpper(group))
+
+	for _, k := range keys {
+		name := k
+		if name == "" {
+			name = "(none)"
+		}
+		printUsage(w, name, groups[k])
+	}
+
+	printUsage(w, "TOTAL", total)
+	w.Flush()
+}
+
+func printUsage(w *tabwriter.Writer, name string, u qbittorrent.DiskUsage) {
+	fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t\n", name, u.Torrents, formatBytes(u.Size), formatBytes(u.Linked), formatBytes(u.Size-u.Linked))
+}
+
+func printSafe(report *qbittorrent.DiskUsageReport) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "HASH\tSIZE\tCATEGORY\tNAME\n")
+
+	for _, t := range report.SafeToDelete() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Hash, formatBytes(t.Size), t.Category, t.Name)
+	}
+
+	w.Flush()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
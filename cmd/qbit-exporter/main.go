@@ -0,0 +1,82 @@
+// Command qbit-exporter serves qBittorrent metrics for Prometheus.
+//
+//	qbit-exporter -host http://localhost:8080 -username admin -listen :9355
+//
+// The password is read from the QBIT_PASSWORD environment variable unless -password is
+// given.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/autobrr/go-qbittorrent/metrics"
+)
+
+func main() {
+	var (
+		host          = flag.String("host", "http://localhost:8080", "qBittorrent WebUI url")
+		username      = flag.String("username", "", "qBittorrent username")
+		password      = flag.String("password", os.Getenv("QBIT_PASSWORD"), "qBittorrent password, defaults to $QBIT_PASSWORD")
+		basicUser     = flag.String("basic-user", "", "HTTP basic auth username")
+		basicPass     = flag.String("basic-pass", os.Getenv("QBIT_BASIC_PASSWORD"), "HTTP basic auth password, defaults to $QBIT_BASIC_PASSWORD")
+		skipVerify    = flag.Bool("tls-skip-verify", false, "skip TLS certificate verification")
+		listen        = flag.String("listen", ":9355", "address to serve metrics on")
+		path          = flag.String("path", "/metrics", "path to serve metrics on")
+		namespace     = flag.String("namespace", "qbittorrent", "metric name prefix")
+		noTorrents    = flag.Bool("no-torrent-metrics", false, "do not export per-torrent metrics")
+		scrapeTimeout = flag.Duration("scrape-timeout", 30*time.Second, "timeout of a single scrape")
+	)
+
+	flag.Parse()
+
+	client := qbittorrent.NewClient(qbittorrent.Config{
+		Host:          *host,
+		Username:      *username,
+		Password:      *password,
+		BasicUser:     *basicUser,
+		BasicPass:     *basicPass,
+		TLSSkipVerify: *skipVerify,
+		Logger:        slog.Default(),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), *scrapeTimeout)
+	if err := client.LoginCtx(ctx); err != nil {
+		log.Fatalf("could not log into qBittorrent: %q", err)
+	}
+	cancel()
+
+	collector := metrics.NewCollector(client, metrics.Options{
+		Namespace:             *namespace,
+		DisableTorrentMetrics: *noTorrents,
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle(*path, http.TimeoutHandler(collector, *scrapeTimeout, "scrape timed out"))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write([]byte("qbit-exporter, metrics are served on " + *path + "\n"))
+	})
+
+	srv := &http.Server{
+		Addr:              *listen,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	log.Printf("serving metrics for %v on %v%v", *host, *listen, *path)
+
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatalf("could not serve metrics: %q", err)
+	}
+}
@@ -0,0 +1,373 @@
+package qbittorrent
+
+import (
+	"context"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+var ErrNoCrossSeedMatch = errors.New("no existing torrent matches the files")
+
+var (
+	// CrossSeedPollInterval is how often AddCrossSeedCtx checks whether an added torrent
+	// exists yet.
+	CrossSeedPollInterval = 500 * time.Millisecond
+
+	// CrossSeedAddTimeout bounds the wait for an added torrent to exist.
+	CrossSeedAddTimeout = 30 * time.Second
+)
+
+type CrossSeedOptions struct {
+	// Torrents are searched for a match, e.g. SyncCache.Torrents. If nil, the completed
+	// torrents of the client are.
+	Torrents []Torrent
+
+	// AllowRenames matches files by size when their names differ. The files of the added
+	// torrent are then renamed to those of the match.
+	AllowRenames bool
+
+	// Category and Tags of the added torrent, by default those of the match.
+	Category string
+	Tags     string
+
+	// Stopped adds the torrent without starting it.
+	Stopped bool
+}
+
+// CrossSeedMatch is an existing torrent whose data a candidate torrent can seed.
+type CrossSeedMatch struct {
+	Torrent Torrent
+
+	ContentPath string
+	SavePath    string
+
+	// ContentLayout and AddSavePath place the files of the candidate onto the data of
+	// the match.
+	ContentLayout ContentLayout
+	AddSavePath   string
+
+	// Renames maps file paths of the added torrent, as qBittorrent names them, to the
+	// paths of the existing files. Empty if all names match.
+	Renames map[string]string
+}
+
+// FindCrossSeedMatchCtx searches for a complete torrent with the same files as candidate:
+// the same total size and, for each file, one of the same size at the same path relative
+// to the torrent root. The torrent root itself may have another name. Torrents with the
+// same name are tried first. It returns ErrNoCrossSeedMatch if there is none.
+func (c *Client) FindCrossSeedMatchCtx(ctx context.Context, candidate *metainfo.MetaInfo, opts CrossSeedOptions) (*CrossSeedMatch, error) {
+	torrents := opts.Torrents
+	if torrents == nil {
+		var err error
+		torrents, err = c.GetTorrentsCtx(ctx, TorrentFilterOptions{Filter: TorrentFilterCompleted})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get torrents")
+		}
+	}
+
+	size := candidate.TotalSize()
+	hash := candidate.Hash()
+
+	var sameSize []Torrent
+	for _, t := range torrents {
+		if t.Progress < 1 || strings.EqualFold(t.Hash, hash) {
+			continue
+		}
+
+		if t.TotalSize == size || t.Size == size {
+			sameSize = append(sameSize, t)
+		}
+	}
+
+	// the name is the best hint, but not a requirement
+	sort.SliceStable(sameSize, func(i, j int) bool {
+		return sameSize[i].Name == candidate.Name() && sameSize[j].Name != candidate.Name()
+	})
+
+	root, files := candidateFiles(candidate)
+
+	for _, t := range sameSize {
+		existing, err := c.GetFilesInformationCtx(ctx, t.Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get files of %v", t.Hash)
+		}
+
+		existingRoot, existingFiles := torrentFiles(*existing)
+
+		renames, ok := matchFiles(files, existingFiles, opts.AllowRenames)
+		if !ok {
+			continue
+		}
+
+		match := &CrossSeedMatch{
+			Torrent:       t,
+			ContentPath:   t.ContentPath,
+			SavePath:      t.SavePath,
+			ContentLayout: ContentLayoutOriginal,
+			AddSavePath:   t.SavePath,
+		}
+
+		if root != existingRoot && existingRoot != "" {
+			// put the files straight into the folder of the match
+			match.AddSavePath = joinSavePath(t.SavePath, existingRoot)
+		}
+
+		// the root of the added torrent, as it names its files
+		addedRoot := root
+		if root != "" && root != existingRoot {
+			match.ContentLayout = ContentLayoutSubfolderNone
+			addedRoot = ""
+		}
+
+		if len(renames) > 0 {
+			match.Renames = make(map[string]string, len(renames))
+			for from, to := range renames {
+				match.Renames[path.Join(addedRoot, from)] = path.Join(addedRoot, to)
+			}
+		}
+
+		return match, nil
+	}
+
+	return nil, ErrNoCrossSeedMatch
+}
+
+// AddCrossSeedCtx adds the torrent buf, parsed as candidate, onto the data of match
+// without checking it. If files must be renamed, the torrent is added stopped, renamed
+// once qBittorrent added it and then started unless opts.Stopped is set. If renaming
+// fails, the torrent is deleted again, without its files.
+func (c *Client) AddCrossSeedCtx(ctx context.Context, buf []byte, candidate *metainfo.MetaInfo, match *CrossSeedMatch, opts CrossSeedOptions) error {
+	stopped := opts.Stopped || len(match.Renames) > 0
+
+	options := map[string]string{
+		"skip_checking": "true",
+		"autoTMM":       "false",
+		"savepath":      match.AddSavePath,
+		"contentLayout": string(match.ContentLayout),
+		"paused":        "false",
+		"stopped":       "false",
+	}
+
+	if match.ContentLayout == ContentLayoutSubfolderNone {
+		// pre qBittorrent version 4.3.2
+		options["root_folder"] = "false"
+	}
+
+	if stopped {
+		options["paused"] = "true"
+		options["stopped"] = "true"
+	}
+
+	options["category"] = match.Torrent.Category
+	if opts.Category != "" {
+		options["category"] = opts.Category
+	}
+
+	options["tags"] = match.Torrent.Tags
+	if opts.Tags != "" {
+		options["tags"] = opts.Tags
+	}
+
+	if err := c.AddTorrentFromMemoryCtx(ctx, buf, options); err != nil {
+		return errors.Wrap(err, "could not add cross-seed")
+	}
+
+	if len(match.Renames) == 0 {
+		return nil
+	}
+
+	hash := candidate.Hash()
+
+	if err := c.renameCrossSeed(ctx, hash, match.Renames); err != nil {
+		// the caller's context may be done by now, cleanup regardless
+		if derr := c.DeleteTorrentsCtx(context.WithoutCancel(ctx), []string{hash}, false); derr != nil {
+			return errors.Wrap(err, "could not delete cross-seed after: %v", derr)
+		}
+
+		return err
+	}
+
+	if opts.Stopped {
+		return nil
+	}
+
+	if err := c.StartCtx(ctx, []string{hash}); err != nil {
+		return errors.Wrap(err, "could not start cross-seed")
+	}
+
+	return nil
+}
+
+// renameCrossSeed waits for the torrent to be added, which qBittorrent does
+// asynchronously, and renames its files.
+func (c *Client) renameCrossSeed(ctx context.Context, hash string, renames map[string]string) error {
+	waitCtx, cancel := context.WithTimeout(ctx, CrossSeedAddTimeout)
+	defer cancel()
+
+	for {
+		_, found, err := getTorrent(waitCtx, c, hash)
+		if err != nil {
+			return errors.Wrap(err, "could not get added cross-seed")
+		}
+
+		if found {
+			break
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return errors.Wrap(waitCtx.Err(), "cross-seed not added")
+		case <-time.After(CrossSeedPollInterval):
+		}
+	}
+
+	// in a stable order, so failures are reproducible
+	from := make([]string, 0, len(renames))
+	for f := range renames {
+		from = append(from, f)
+	}
+	sort.Strings(from)
+
+	for _, f := range from {
+		if err := c.RenameFileCtx(ctx, hash, f, renames[f]); err != nil {
+			return errors.Wrap(err, "could not rename cross-seed file")
+		}
+	}
+
+	return nil
+}
+
+// CrossSeedCtx parses the torrent buf, searches for a match with FindCrossSeedMatchCtx
+// and adds it with AddCrossSeedCtx. It returns ErrNoCrossSeedMatch without adding
+// anything if there is no match.
+func (c *Client) CrossSeedCtx(ctx context.Context, buf []byte, opts CrossSeedOptions) (*CrossSeedMatch, error) {
+	candidate, err := metainfo.ParseTorrent(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse torrent")
+	}
+
+	match, err := c.FindCrossSeedMatchCtx(ctx, candidate, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.AddCrossSeedCtx(ctx, buf, candidate, match, opts); err != nil {
+		return nil, err
+	}
+
+	return match, nil
+}
+
+// candidateFiles returns the root folder of a torrent, empty for a single file, and the
+// sizes of its files by path relative to that root.
+func candidateFiles(m *metainfo.MetaInfo) (string, map[string]int64) {
+	root := ""
+	if m.Info.IsMultiFile() {
+		root = m.Name()
+	}
+
+	files := map[string]int64{}
+	for _, f := range m.Files() {
+		p := path.Join(f.Path...)
+		if root != "" {
+			p = strings.TrimPrefix(p, root+"/")
+		}
+		files[p] = f.Length
+	}
+
+	return root, files
+}
+
+// torrentFiles is candidateFiles for the files of an existing torrent. Their root is the
+// first path element if all files share it.
+func torrentFiles(tf TorrentFiles) (string, map[string]int64) {
+	root := ""
+	for i, f := range tf {
+		first, _, found := strings.Cut(f.Name, "/")
+		if !found || (i > 0 && first != root) {
+			root = ""
+			break
+		}
+		root = first
+	}
+
+	files := map[string]int64{}
+	for _, f := range tf {
+		p := f.Name
+		if root != "" {
+			p = strings.TrimPrefix(p, root+"/")
+		}
+		files[p] = f.Size
+	}
+
+	return root, files
+}
+
+// matchFiles reports whether files, by relative path, are the same as existing. With
+// allowRenames files left over are paired by size, in name order among equal sizes, and
+// returned as renames from the candidate path to the existing path.
+func matchFiles(files, existing map[string]int64, allowRenames bool) (map[string]string, bool) {
+	if len(files) != len(existing) {
+		return nil, false
+	}
+
+	var unmatched, unmatchedExisting []string
+	for p, size := range files {
+		if existingSize, ok := existing[p]; ok && existingSize == size {
+			continue
+		}
+		unmatched = append(unmatched, p)
+	}
+
+	if len(unmatched) == 0 {
+		return nil, true
+	}
+
+	if !allowRenames {
+		return nil, false
+	}
+
+	for p, size := range existing {
+		if candidateSize, ok := files[p]; ok && candidateSize == size {
+			continue
+		}
+		unmatchedExisting = append(unmatchedExisting, p)
+	}
+
+	bySize := func(paths []string, sizes map[string]int64) {
+		sort.Slice(paths, func(i, j int) bool {
+			if sizes[paths[i]] != sizes[paths[j]] {
+				return sizes[paths[i]] < sizes[paths[j]]
+			}
+			return paths[i] < paths[j]
+		})
+	}
+	bySize(unmatched, files)
+	bySize(unmatchedExisting, existing)
+
+	renames := make(map[string]string, len(unmatched))
+	for i, p := range unmatched {
+		if files[p] != existing[unmatchedExisting[i]] {
+			return nil, false
+		}
+		renames[p] = unmatchedExisting[i]
+	}
+
+	return renames, true
+}
+
+// joinSavePath joins a save path as qBittorrent reports it, which may use either
+// separator, with a relative path.
+func joinSavePath(savePath, elem string) string {
+	sep := "/"
+	if strings.Contains(savePath, `\`) && !strings.Contains(savePath, "/") {
+		sep = `\`
+	}
+
+	return strings.TrimRight(savePath, `/\`) + sep + elem
+}
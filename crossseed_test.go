@@ -0,0 +1,248 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+type crossSeedFile struct {
+	path string
+	size int64
+}
+
+// buildTorrent encodes a v1 torrent. A single file without a slash in its path makes a
+// single file torrent named after it.
+func buildTorrent(t *testing.T, name string, files ...crossSeedFile) []byte {
+	t.Helper()
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": 16384,
+		"pieces":       strings.Repeat("x", 20),
+	}
+
+	if len(files) == 1 && !strings.Contains(files[0].path, "/") && files[0].path == name {
+		info["length"] = files[0].size
+	} else {
+		var list []interface{}
+		for _, f := range files {
+			var p []interface{}
+			for _, elem := range strings.Split(f.path, "/") {
+				p = append(p, elem)
+			}
+			list = append(list, map[string]interface{}{"length": f.size, "path": p})
+		}
+		info["files"] = list
+	}
+
+	buf, err := metainfo.Encode(map[string]interface{}{"info": info})
+	require.NoError(t, err)
+
+	return buf
+}
+
+func TestClient_FindCrossSeedMatchCtx(t *testing.T) {
+	existing := []Torrent{
+		{Hash: "h1", Name: "Show.S01.720p", Progress: 1, TotalSize: 30, SavePath: "/data/tv", ContentPath: "/data/tv/Show.S01.720p", Category: "tv"},
+		{Hash: "h2", Name: "Show.S01", Progress: 1, TotalSize: 30, SavePath: "/data/tv", ContentPath: "/data/tv/Show.S01"},
+		{Hash: "h3", Name: "Movie.mkv", Progress: 1, TotalSize: 50, SavePath: "/data/movies", ContentPath: "/data/movies/Movie.mkv"},
+		{Hash: "h4", Name: "Incomplete", Progress: 0.5, TotalSize: 30},
+	}
+
+	files := map[string]string{
+		"h1": `[{"name":"Show.S01.720p/e01.mkv","size":10},{"name":"Show.S01.720p/e02.mkv","size":20}]`,
+		"h2": `[{"name":"Show.S01/e01.mkv","size":10},{"name":"Show.S01/e02.mkv","size":20}]`,
+		"h3": `[{"name":"Movie.mkv","size":50}]`,
+	}
+
+	var (
+		mu    sync.Mutex
+		asked []string
+	)
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			asked = append(asked, r.FormValue("hash"))
+			mu.Unlock()
+
+			_, _ = w.Write([]byte(files[r.FormValue("hash")]))
+		},
+	})
+
+	tests := []struct {
+		name         string
+		torrent      []byte
+		allowRenames bool
+		want         *CrossSeedMatch
+		wantAsked    []string
+	}{
+		{
+			name:      "same_name_first",
+			torrent:   buildTorrent(t, "Show.S01", crossSeedFile{"e01.mkv", 10}, crossSeedFile{"e02.mkv", 20}),
+			want:      &CrossSeedMatch{Torrent: existing[1], ContentPath: "/data/tv/Show.S01", SavePath: "/data/tv", ContentLayout: ContentLayoutOriginal, AddSavePath: "/data/tv"},
+			wantAsked: []string{"h2"},
+		},
+		{
+			name:      "other_root",
+			torrent:   buildTorrent(t, "Show.S01.WEB", crossSeedFile{"e01.mkv", 10}, crossSeedFile{"e02.mkv", 20}),
+			want:      &CrossSeedMatch{Torrent: existing[0], ContentPath: "/data/tv/Show.S01.720p", SavePath: "/data/tv", ContentLayout: ContentLayoutSubfolderNone, AddSavePath: "/data/tv/Show.S01.720p"},
+			wantAsked: []string{"h1"},
+		},
+		{
+			name:      "names_differ",
+			torrent:   buildTorrent(t, "Show.S01", crossSeedFile{"Show.E01.mkv", 10}, crossSeedFile{"Show.E02.mkv", 20}),
+			wantAsked: []string{"h2", "h1"},
+		},
+		{
+			name:         "renamed",
+			torrent:      buildTorrent(t, "Show.S01", crossSeedFile{"Show.E01.mkv", 10}, crossSeedFile{"Show.E02.mkv", 20}),
+			allowRenames: true,
+			want: &CrossSeedMatch{Torrent: existing[1], ContentPath: "/data/tv/Show.S01", SavePath: "/data/tv", ContentLayout: ContentLayoutOriginal, AddSavePath: "/data/tv",
+				Renames: map[string]string{"Show.S01/Show.E01.mkv": "Show.S01/e01.mkv", "Show.S01/Show.E02.mkv": "Show.S01/e02.mkv"}},
+			wantAsked: []string{"h2"},
+		},
+		{
+			name:         "single_file_renamed",
+			torrent:      buildTorrent(t, "Movie.2024.mkv", crossSeedFile{"Movie.2024.mkv", 50}),
+			allowRenames: true,
+			want: &CrossSeedMatch{Torrent: existing[2], ContentPath: "/data/movies/Movie.mkv", SavePath: "/data/movies", ContentLayout: ContentLayoutOriginal, AddSavePath: "/data/movies",
+				Renames: map[string]string{"Movie.2024.mkv": "Movie.mkv"}},
+			wantAsked: []string{"h3"},
+		},
+		{
+			name:    "size_differs",
+			torrent: buildTorrent(t, "Show.S01", crossSeedFile{"e01.mkv", 10}, crossSeedFile{"e02.mkv", 21}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			asked = nil
+
+			candidate, err := metainfo.ParseTorrent(tt.torrent)
+			require.NoError(t, err)
+
+			match, err := client.FindCrossSeedMatchCtx(context.Background(), candidate, CrossSeedOptions{Torrents: existing, AllowRenames: tt.allowRenames})
+			if tt.want == nil {
+				assert.ErrorIs(t, err, ErrNoCrossSeedMatch)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.want, match)
+			assert.Equal(t, tt.wantAsked, asked)
+		})
+	}
+}
+
+func TestClient_CrossSeedCtx(t *testing.T) {
+	setForTest(t, &CrossSeedPollInterval, 10*time.Millisecond)
+
+	buf := buildTorrent(t, "Show.S01", crossSeedFile{"Show.E01.mkv", 10}, crossSeedFile{"e02.mkv", 20})
+
+	candidate, err := metainfo.ParseTorrent(buf)
+	require.NoError(t, err)
+	hash := candidate.Hash()
+
+	tests := []struct {
+		name       string
+		failRename bool
+		wantErr    bool
+		wantCalls  []string
+	}{
+		{
+			name:      "renamed",
+			wantCalls: []string{"add", "rename Show.S01/Show.E01.mkv Show.S01/e01.mkv", "start"},
+		},
+		{
+			name:       "rename_failed",
+			failRename: true,
+			wantErr:    true,
+			wantCalls:  []string{"add", "rename Show.S01/Show.E01.mkv Show.S01/e01.mkv", "delete " + hash + " false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				calls   callRecorder
+				polls   atomic.Int32
+				options map[string]string
+			)
+
+			client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+				"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+					if r.FormValue("hashes") != "" {
+						// added asynchronously, not there on the first poll
+						if polls.Add(1) == 1 {
+							_, _ = w.Write([]byte(`[]`))
+							return
+						}
+						_ = json.NewEncoder(w).Encode([]Torrent{{Hash: r.FormValue("hashes")}})
+						return
+					}
+
+					assert.Equal(t, "completed", r.FormValue("filter"))
+					_ = json.NewEncoder(w).Encode([]Torrent{{Hash: "h1", Name: "Show.S01", Progress: 1, TotalSize: 30, SavePath: "/data/tv", Category: "tv", Tags: "a"}})
+				},
+				"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(`[{"name":"Show.S01/e01.mkv","size":10},{"name":"Show.S01/e02.mkv","size":20}]`))
+				},
+				"torrents/add": func(w http.ResponseWriter, r *http.Request) {
+					calls.record("add")
+					require.NoError(t, r.ParseMultipartForm(1<<20))
+
+					options = map[string]string{}
+					for k, v := range r.MultipartForm.Value {
+						options[k] = v[0]
+					}
+				},
+				"torrents/renameFile": func(w http.ResponseWriter, r *http.Request) {
+					calls.record("rename " + r.FormValue("oldPath") + " " + r.FormValue("newPath"))
+					if tt.failRename {
+						w.WriteHeader(http.StatusConflict)
+					}
+				},
+				"torrents/start": func(w http.ResponseWriter, r *http.Request) {
+					calls.record("start")
+				},
+				"torrents/delete": func(w http.ResponseWriter, r *http.Request) {
+					calls.record("delete " + r.FormValue("hashes") + " " + r.FormValue("deleteFiles"))
+				},
+			})
+
+			match, err := client.CrossSeedCtx(context.Background(), buf, CrossSeedOptions{AllowRenames: true, Tags: "cross-seed"})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "h1", match.Torrent.Hash)
+			}
+
+			assert.Equal(t, tt.wantCalls, calls.get())
+			assert.Equal(t, int32(2), polls.Load())
+			assert.Equal(t, map[string]string{
+				"skip_checking": "true",
+				"autoTMM":       "false",
+				"savepath":      "/data/tv",
+				"contentLayout": "Original",
+				"paused":        "true",
+				"stopped":       "true",
+				"category":      "tv",
+				"tags":          "cross-seed",
+			}, options)
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package qbittorrent
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+type DiskUsageOptions struct {
+	// PathMap maps path prefixes as qBittorrent reports them to local paths, see
+	// ScanOptions.PathMap.
+	PathMap map[string]string
+
+	// Torrents are accounted. If nil, all torrents of the client are.
+	Torrents []Torrent
+}
+
+// DiskUsage is the space used by a group of torrents. Files linked more than once,
+// by several torrents or hard links, are counted once.
+type DiskUsage struct {
+	Torrents int
+	Size     int64
+
+	// Linked is the part of Size also linked from outside the torrents, e.g. a media
+	// library, and not freed by deleting them.
+	Linked int64
+}
+
+type TorrentDiskUsage struct {
+	Hash     string
+	Name     string
+	Category string
+	Tags     []string
+	Tracker  string // host
+
+	DiskUsage
+
+	// Missing counts the files not found on disk.
+	Missing int
+
+	// SafeToDelete is set if every file of the torrent is on disk, hard linked from
+	// outside the torrents and not shared with another torrent at the same path, so
+	// deleting it with its files frees no space and loses no data.
+	SafeToDelete bool
+}
+
+type DiskUsageReport struct {
+	Torrents []TorrentDiskUsage
+
+	Total      DiskUsage
+	ByCategory map[string]DiskUsage
+	ByTag      map[string]DiskUsage
+	ByTracker  map[string]DiskUsage // by host, "" for torrents without a working tracker
+}
+
+// SafeToDelete returns the torrents that are safe to delete, see
+// TorrentDiskUsage.SafeToDelete.
+func (r *DiskUsageReport) SafeToDelete() []TorrentDiskUsage {
+	var safe []TorrentDiskUsage
+	for _, t := range r.Torrents {
+		if t.SafeToDelete {
+			safe = append(safe, t)
+		}
+	}
+
+	return safe
+}
+
+// fileID identifies a file on disk; path is only set where hard links cannot be told.
+type fileID struct {
+	dev, ino uint64
+	path     string
+}
+
+type diskFile struct {
+	size  int64
+	links uint64
+	paths map[string]int // local paths the torrents reference it by, with their number of torrents
+}
+
+// diskFileRef is a file of a torrent.
+type diskFileRef struct {
+	id   fileID
+	path string
+}
+
+// linked reports whether f has hard links no torrent references.
+func (f *diskFile) linked() bool {
+	return f.links > uint64(len(f.paths))
+}
+
+// DiskUsageCtx stats the files of torrents on the local filesystem and sums their size by
+// torrent, category, tag and tracker, telling apart data that is also hard linked from
+// elsewhere. Hard links are only detected on unix systems.
+func (c *Client) DiskUsageCtx(ctx context.Context, opts DiskUsageOptions) (*DiskUsageReport, error) {
+	torrents := opts.Torrents
+	if torrents == nil {
+		var err error
+		torrents, err = c.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get torrents")
+		}
+	}
+
+	files := map[fileID]*diskFile{}
+	torrentFiles := make([][]diskFileRef, len(torrents))
+
+	report := &DiskUsageReport{
+		Torrents:   make([]TorrentDiskUsage, len(torrents)),
+		ByCategory: map[string]DiskUsage{},
+		ByTag:      map[string]DiskUsage{},
+		ByTracker:  map[string]DiskUsage{},
+	}
+
+	for i, t := range torrents {
+		tf, err := c.GetFilesInformationCtx(ctx, t.Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get files of %v", t.Hash)
+		}
+
+		report.Torrents[i] = TorrentDiskUsage{
+			Hash:     t.Hash,
+			Name:     t.Name,
+			Category: t.Category,
+			Tags:     splitTags(t.Tags),
+			Tracker:  trackerHost(t.Tracker),
+		}
+
+		base := mapLocalPath(opts.PathMap, torrentBasePath(t))
+
+		for _, f := range *tf {
+			p := filepath.Join(base, filepath.FromSlash(f.Name))
+
+			fi, err := os.Stat(p)
+			if err != nil {
+				// incomplete files may carry the suffix
+				p += incompleteSuffix
+				fi, err = os.Stat(p)
+			}
+			if err != nil {
+				report.Torrents[i].Missing++
+				continue
+			}
+
+			id, links, ok := fileLinks(fi)
+			if !ok {
+				id, links = fileID{path: p}, 1
+			}
+
+			df, ok := files[id]
+			if !ok {
+				df = &diskFile{size: fi.Size(), links: links, paths: map[string]int{}}
+				files[id] = df
+			}
+			df.paths[p]++
+
+			torrentFiles[i] = append(torrentFiles[i], diskFileRef{id: id, path: p})
+		}
+	}
+
+	var (
+		all        []fileID
+		byCategory = map[string][]fileID{}
+		byTag      = map[string][]fileID{}
+		byTracker  = map[string][]fileID{}
+	)
+
+	for i := range report.Torrents {
+		tu := &report.Torrents[i]
+
+		ids := make([]fileID, 0, len(torrentFiles[i]))
+		tu.SafeToDelete = tu.Missing == 0 && len(torrentFiles[i]) > 0
+		for _, ref := range torrentFiles[i] {
+			ids = append(ids, ref.id)
+
+			f := files[ref.id]
+			if !f.linked() || f.paths[ref.path] > 1 {
+				tu.SafeToDelete = false
+			}
+		}
+
+		tu.DiskUsage = sumDiskUsage(files, ids, 1)
+
+		all = append(all, ids...)
+		byCategory[tu.Category] = append(byCategory[tu.Category], ids...)
+		byTracker[tu.Tracker] = append(byTracker[tu.Tracker], ids...)
+		for _, tag := range tu.Tags {
+			byTag[tag] = append(byTag[tag], ids...)
+		}
+
+		addDiskUsageTorrent(report.ByCategory, tu.Category)
+		addDiskUsageTorrent(report.ByTracker, tu.Tracker)
+		for _, tag := range tu.Tags {
+			addDiskUsageTorrent(report.ByTag, tag)
+		}
+	}
+
+	report.Total = sumDiskUsage(files, all, len(report.Torrents))
+
+	sumGroups(report.ByCategory, files, byCategory)
+	sumGroups(report.ByTag, files, byTag)
+	sumGroups(report.ByTracker, files, byTracker)
+
+	return report, nil
+}
+
+func sumDiskUsage(files map[fileID]*diskFile, ids []fileID, torrents int) DiskUsage {
+	u := DiskUsage{Torrents: torrents}
+
+	seen := make(map[fileID]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		f := files[id]
+		u.Size += f.size
+		if f.linked() {
+			u.Linked += f.size
+		}
+	}
+
+	return u
+}
+
+func addDiskUsageTorrent(m map[string]DiskUsage, key string) {
+	u := m[key]
+	u.Torrents++
+	m[key] = u
+}
+
+// sumGroups sums the files of each group into dest, keeping the torrent counts.
+func sumGroups(dest map[string]DiskUsage, files map[fileID]*diskFile, groups map[string][]fileID) {
+	for key, ids := range groups {
+		dest[key] = sumDiskUsage(files, ids, dest[key].Torrents)
+	}
+}
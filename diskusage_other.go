@@ -0,0 +1,10 @@
+//go:build !unix
+
+package qbittorrent
+
+import "io/fs"
+
+// fileLinks is not supported on this platform, files are counted as not linked.
+func fileLinks(fi fs.FileInfo) (fileID, uint64, bool) {
+	return fileID{}, 0, false
+}
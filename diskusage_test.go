@@ -0,0 +1,113 @@
+//go:build unix
+
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_DiskUsageCtx(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data")
+	library := filepath.Join(dir, "library")
+
+	// movie and movie-xs: the same file, hard linked into the library
+	writeTestFile(t, filepath.Join(data, "movies", "Movie.mkv"), 100)
+	require.NoError(t, os.MkdirAll(library, 0o755))
+	require.NoError(t, os.Link(filepath.Join(data, "movies", "Movie.mkv"), filepath.Join(library, "Movie.mkv")))
+
+	// lib: hard linked into the library, safe to delete
+	writeTestFile(t, filepath.Join(data, "movies", "Lib.mkv"), 40)
+	require.NoError(t, os.Link(filepath.Join(data, "movies", "Lib.mkv"), filepath.Join(library, "Lib.mkv")))
+
+	// show: one of two episodes in the library
+	writeTestFile(t, filepath.Join(data, "tv", "Show", "e01.mkv"), 10)
+	writeTestFile(t, filepath.Join(data, "tv", "Show", "e02.mkv"), 20)
+	require.NoError(t, os.Link(filepath.Join(data, "tv", "Show", "e01.mkv"), filepath.Join(library, "e01.mkv")))
+
+	// linux and linux2: hard links of each other, not safe to delete
+	writeTestFile(t, filepath.Join(data, "linux", "linux.iso"), 50)
+	require.NoError(t, os.MkdirAll(filepath.Join(data, "linux2"), 0o755))
+	require.NoError(t, os.Link(filepath.Join(data, "linux", "linux.iso"), filepath.Join(data, "linux2", "linux.iso")))
+
+	files := map[string]string{
+		"movie":    `[{"name":"Movie.mkv","size":100}]`,
+		"movie-xs": `[{"name":"Movie.mkv","size":100}]`,
+		"show":     `[{"name":"Show/e01.mkv","size":10},{"name":"Show/e02.mkv","size":20}]`,
+		"linux":    `[{"name":"linux.iso","size":50}]`,
+		"linux2":   `[{"name":"linux.iso","size":50}]`,
+		"gone":     `[{"name":"Gone.mkv","size":5}]`,
+		"lib":      `[{"name":"Lib.mkv","size":40}]`,
+	}
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[
+				{"hash":"movie","name":"Movie","category":"movies","tags":"a, b","tracker":"https://t1.example/announce","save_path":"/downloads/movies"},
+				{"hash":"movie-xs","name":"Movie","category":"movies","tags":"b","tracker":"https://T2.example/announce","save_path":"/downloads/movies"},
+				{"hash":"show","name":"Show","category":"tv","tracker":"https://t1.example/announce","save_path":"/downloads/tv"},
+				{"hash":"linux","name":"linux","save_path":"/downloads/linux"},
+				{"hash":"linux2","name":"linux","save_path":"/downloads/linux2"},
+				{"hash":"gone","name":"Gone","category":"movies","save_path":"/downloads/movies"},
+				{"hash":"lib","name":"Lib","category":"movies","save_path":"/downloads/movies"}
+			]`))
+		},
+		"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(files[r.FormValue("hash")]))
+		},
+	})
+
+	report, err := client.DiskUsageCtx(context.Background(), DiskUsageOptions{PathMap: map[string]string{"/downloads": data}})
+	require.NoError(t, err)
+
+	byHash := map[string]TorrentDiskUsage{}
+	for _, tu := range report.Torrents {
+		byHash[tu.Hash] = tu
+	}
+
+	// deleting one would delete the data of the other
+	assert.Equal(t, TorrentDiskUsage{
+		Hash: "movie", Name: "Movie", Category: "movies", Tags: []string{"a", "b"}, Tracker: "t1.example",
+		DiskUsage: DiskUsage{Torrents: 1, Size: 100, Linked: 100},
+	}, byHash["movie"])
+	assert.False(t, byHash["movie-xs"].SafeToDelete)
+	assert.Equal(t, DiskUsage{Torrents: 1, Size: 30, Linked: 10}, byHash["show"].DiskUsage)
+	assert.False(t, byHash["show"].SafeToDelete)
+	assert.Equal(t, DiskUsage{Torrents: 1, Size: 50}, byHash["linux"].DiskUsage)
+	assert.False(t, byHash["linux"].SafeToDelete)
+	assert.Equal(t, 1, byHash["gone"].Missing)
+	assert.False(t, byHash["gone"].SafeToDelete)
+
+	var safe []string
+	for _, tu := range report.SafeToDelete() {
+		safe = append(safe, tu.Hash)
+	}
+	assert.Equal(t, []string{"lib"}, safe)
+
+	// the movie and the linux iso are counted once
+	assert.Equal(t, DiskUsage{Torrents: 7, Size: 220, Linked: 150}, report.Total)
+
+	assert.Equal(t, map[string]DiskUsage{
+		"movies": {Torrents: 4, Size: 140, Linked: 140},
+		"tv":     {Torrents: 1, Size: 30, Linked: 10},
+		"":       {Torrents: 2, Size: 50},
+	}, report.ByCategory)
+
+	assert.Equal(t, map[string]DiskUsage{
+		"a": {Torrents: 1, Size: 100, Linked: 100},
+		"b": {Torrents: 2, Size: 100, Linked: 100},
+	}, report.ByTag)
+
+	assert.Equal(t, map[string]DiskUsage{
+		"t1.example": {Torrents: 2, Size: 130, Linked: 110},
+		"t2.example": {Torrents: 1, Size: 100, Linked: 100},
+		"":           {Torrents: 4, Size: 90, Linked: 40},
+	}, report.ByTracker)
+}
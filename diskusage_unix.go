@@ -0,0 +1,18 @@
+//go:build unix
+
+package qbittorrent
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileLinks returns the identity of the file behind fi and its number of hard links.
+func fileLinks(fi fs.FileInfo) (fileID, uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, 0, false
+	}
+
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
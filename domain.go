@@ -1,14 +1,19 @@
 package qbittorrent
 
 import (
+	"encoding/json"
 	"strconv"
+	"strings"
 
 	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/magnet"
 )
 
 var (
 	ErrReannounceTookTooLong = errors.New("reannounce took too long, deleted torrent")
 	ErrUnsupportedVersion    = errors.New("qBittorrent version too old, please upgrade to use this feature")
+	ErrInstanceUnavailable   = errors.New("qBittorrent instance unavailable, too many failed requests")
+	ErrLoginFailed           = errors.New("qBittorrent login failed")
 )
 
 type Torrent struct {
@@ -62,6 +67,39 @@ type Torrent struct {
 	UploadedSession    int64            `json:"uploaded_session"`
 	UpSpeed            int64            `json:"upspeed"`
 	Trackers           []TorrentTracker `json:"trackers"`
+
+	// InactiveSeedingTimeLimit is in minutes, reported since WebAPI 2.9.2.
+	InactiveSeedingTimeLimit int64 `json:"inactive_seeding_time_limit"`
+}
+
+// Magnet builds a magnet for the torrent from its infohashes, name, size and trackers.
+// Unlike MagnetURI, it includes the v2 infohash of hybrid and v2 torrents.
+func (t *Torrent) Magnet() *magnet.Magnet {
+	m := &magnet.Magnet{
+		InfoHashV1:  strings.ToLower(t.InfohashV1),
+		InfoHashV2:  strings.ToLower(t.InfohashV2),
+		DisplayName: t.Name,
+		ExactLength: t.TotalSize,
+	}
+
+	// torrents added before metadata was fetched only carry their hash
+	if m.InfoHashV1 == "" && m.InfoHashV2 == "" {
+		m.InfoHashV1 = strings.ToLower(t.Hash)
+	}
+
+	for _, tracker := range t.Trackers {
+		// skip the DHT, PeX and LSD pseudo trackers
+		if tracker.Status == TrackerStatusDisabled {
+			continue
+		}
+		m.Trackers = append(m.Trackers, tracker.Url)
+	}
+
+	if len(m.Trackers) == 0 && t.Tracker != "" {
+		m.Trackers = []string{t.Tracker}
+	}
+
+	return m
 }
 
 type TorrentTrackersResponse struct {
@@ -271,6 +309,15 @@ const (
 	ContentLayoutSubfolderCreate ContentLayout = "Subfolder"
 )
 
+// TorrentStopCondition tells qBittorrent when to stop a newly added torrent.
+type TorrentStopCondition string
+
+const (
+	TorrentStopConditionNone             TorrentStopCondition = "None"
+	TorrentStopConditionMetadataReceived TorrentStopCondition = "MetadataReceived"
+	TorrentStopConditionFilesChecked     TorrentStopCondition = "FilesChecked"
+)
+
 type TorrentAddOptions struct {
 	Stopped            bool // introduced in Web API v2.11.0 (v5.0.0)
 	Paused             bool
@@ -287,6 +334,7 @@ type TorrentAddOptions struct {
 	Rename             string
 	FirstLastPiecePrio bool
 	SequentialDownload bool
+	StopCondition      TorrentStopCondition // introduced in Web API v2.9.2 (v4.6.0)
 }
 
 func (o *TorrentAddOptions) Prepare() map[string]string {
@@ -355,6 +403,10 @@ func (o *TorrentAddOptions) Prepare() map[string]string {
 		options["sequentialDownload"] = "true"
 	}
 
+	if o.StopCondition != "" {
+		options["stopCondition"] = string(o.StopCondition)
+	}
+
 	return options
 }
 
@@ -370,6 +422,53 @@ type TorrentFilterOptions struct {
 	IncludeTrackers bool // qbit 5.1+
 }
 
+// TorrentMetadata is the metadata of a torrent that has not necessarily been added,
+// as returned by torrents/fetchMetadata and torrents/parseMetadata.
+type TorrentMetadata struct {
+	InfohashV1   string                   `json:"infohash_v1"`
+	InfohashV2   string                   `json:"infohash_v2"`
+	Comment      string                   `json:"comment"`
+	CreatedBy    string                   `json:"created_by"`
+	CreationDate int64                    `json:"creation_date"`
+	Info         TorrentMetadataInfo      `json:"info"`
+	Trackers     []TorrentMetadataTracker `json:"trackers"`
+	WebSeeds     []string                 `json:"webseeds"`
+}
+
+type TorrentMetadataInfo struct {
+	Name        string                `json:"name"`
+	Length      int64                 `json:"length"`
+	PieceLength int64                 `json:"piece_length"`
+	PiecesNum   int64                 `json:"pieces_num"`
+	Private     bool                  `json:"private"`
+	Files       []TorrentMetadataFile `json:"files"`
+}
+
+type TorrentMetadataFile struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+}
+
+type TorrentMetadataTracker struct {
+	Url  string `json:"url"`
+	Tier int    `json:"tier"`
+}
+
+// TotalSize returns the summed size of all files.
+func (m *TorrentMetadata) TotalSize() int64 {
+	if m.Info.Length > 0 {
+		return m.Info.Length
+	}
+
+	var size int64
+	for _, f := range m.Info.Files {
+		size += f.Size
+	}
+
+	return size
+}
+
 type TorrentProperties struct {
 	AdditionDate           int     `json:"addition_date"`
 	Comment                string  `json:"comment"`
@@ -600,34 +699,79 @@ type MainData struct {
 	Tags              []string            `json:"tags"`
 	TagsRemoved       []string            `json:"tags_removed"`
 	Trackers          map[string][]string `json:"trackers"`
+	TrackersRemoved   []string            `json:"trackers_removed"`
 	ServerState       ServerState         `json:"server_state"`
 }
 
 type ServerState struct {
-	AlltimeDl            int64  `json:"alltime_dl"`
-	AlltimeUl            int64  `json:"alltime_ul"`
-	AverageTimeQueue     int64  `json:"average_time_queue"`
-	ConnectionStatus     string `json:"connection_status"`
-	DhtNodes             int64  `json:"dht_nodes"`
-	DlInfoData           int64  `json:"dl_info_data"`
-	DlInfoSpeed          int64  `json:"dl_info_speed"`
-	DlRateLimit          int64  `json:"dl_rate_limit"`
-	FreeSpaceOnDisk      int64  `json:"free_space_on_disk"`
-	GlobalRatio          string `json:"global_ratio"`
-	QueuedIoJobs         int64  `json:"queued_io_jobs"`
-	Queueing             bool   `json:"queueing"`
-	ReadCacheHits        string `json:"read_cache_hits"`
-	ReadCacheOverload    string `json:"read_cache_overload"`
-	RefreshInterval      int64  `json:"refresh_interval"`
-	TotalBuffersSize     int64  `json:"total_buffers_size"`
-	TotalPeerConnections int64  `json:"total_peer_connections"`
-	TotalQueuedSize      int64  `json:"total_queued_size"`
-	TotalWastedSession   int64  `json:"total_wasted_session"`
-	UpInfoData           int64  `json:"up_info_data"`
-	UpInfoSpeed          int64  `json:"up_info_speed"`
-	UpRateLimit          int64  `json:"up_rate_limit"`
-	UseAltSpeedLimits    bool   `json:"use_alt_speed_limits"`
-	WriteCacheOverload   string `json:"write_cache_overload"`
+	AlltimeDl            int64   `json:"alltime_dl"`
+	AlltimeUl            int64   `json:"alltime_ul"`
+	AverageTimeQueue     int64   `json:"average_time_queue"`
+	ConnectionStatus     string  `json:"connection_status"`
+	DhtNodes             int64   `json:"dht_nodes"`
+	DlInfoData           int64   `json:"dl_info_data"`
+	DlInfoSpeed          int64   `json:"dl_info_speed"`
+	DlRateLimit          int64   `json:"dl_rate_limit"`
+	FreeSpaceOnDisk      int64   `json:"free_space_on_disk"`
+	GlobalRatio          float64 `json:"global_ratio"`
+	QueuedIoJobs         int64   `json:"queued_io_jobs"`
+	Queueing             bool    `json:"queueing"`
+	ReadCacheHits        float64 `json:"read_cache_hits"`
+	ReadCacheOverload    float64 `json:"read_cache_overload"`
+	RefreshInterval      int64   `json:"refresh_interval"`
+	TotalBuffersSize     int64   `json:"total_buffers_size"`
+	TotalPeerConnections int64   `json:"total_peer_connections"`
+	TotalQueuedSize      int64   `json:"total_queued_size"`
+	TotalWastedSession   int64   `json:"total_wasted_session"`
+	UpInfoData           int64   `json:"up_info_data"`
+	UpInfoSpeed          int64   `json:"up_info_speed"`
+	UpRateLimit          int64   `json:"up_rate_limit"`
+	UseAltSpeedLimits    bool    `json:"use_alt_speed_limits"`
+	WriteCacheOverload   float64 `json:"write_cache_overload"`
+}
+
+// UnmarshalJSON decodes the ratio and cache fields qBittorrent sends as strings, such as
+// "1.50". Values that are not a number, like the "-" ratio before anything was
+// transferred, decode to 0. Fields missing from data keep their value.
+func (s *ServerState) UnmarshalJSON(data []byte) error {
+	type serverState ServerState
+
+	aux := struct {
+		*serverState
+		GlobalRatio        json.RawMessage `json:"global_ratio"`
+		ReadCacheHits      json.RawMessage `json:"read_cache_hits"`
+		ReadCacheOverload  json.RawMessage `json:"read_cache_overload"`
+		WriteCacheOverload json.RawMessage `json:"write_cache_overload"`
+	}{
+		serverState: (*serverState)(s),
+	}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	for raw, dest := range map[*json.RawMessage]*float64{
+		&aux.GlobalRatio:        &s.GlobalRatio,
+		&aux.ReadCacheHits:      &s.ReadCacheHits,
+		&aux.ReadCacheOverload:  &s.ReadCacheOverload,
+		&aux.WriteCacheOverload: &s.WriteCacheOverload,
+	} {
+		if len(*raw) > 0 {
+			*dest = parseNumericString(*raw)
+		}
+	}
+
+	return nil
+}
+
+// parseNumericString reads a JSON number or a string holding one.
+func parseNumericString(raw json.RawMessage) float64 {
+	v, err := strconv.ParseFloat(strings.Trim(string(raw), `"`), 64)
+	if err != nil {
+		return 0
+	}
+
+	return v
 }
 
 // Log
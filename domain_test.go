@@ -21,6 +21,7 @@ func TestTorrentAddOptions_Prepare(t *testing.T) {
 		LimitSeedTime      int64
 		Rename             string
 		FirstLastPiecePrio bool
+		StopCondition      TorrentStopCondition
 	}
 	tests := []struct {
 		name   string
@@ -216,6 +217,21 @@ func TestTorrentAddOptions_Prepare(t *testing.T) {
 				"rename":             "test-torrent-rename",
 			},
 		},
+		{
+			name: "test_07",
+			fields: fields{
+				Paused:        true,
+				SkipHashCheck: false,
+				ContentLayout: ContentLayoutOriginal,
+				StopCondition: TorrentStopConditionMetadataReceived,
+			},
+			want: map[string]string{
+				"paused":             "true",
+				"stopped":            "true",
+				"firstLastPiecePrio": "false",
+				"stopCondition":      "MetadataReceived",
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -233,6 +249,7 @@ func TestTorrentAddOptions_Prepare(t *testing.T) {
 				LimitSeedTime:      tt.fields.LimitSeedTime,
 				Rename:             tt.fields.Rename,
 				FirstLastPiecePrio: tt.fields.FirstLastPiecePrio,
+				StopCondition:      tt.fields.StopCondition,
 			}
 
 			got := o.Prepare()
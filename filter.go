@@ -0,0 +1,655 @@
+package qbittorrent
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// Filter is a compiled filter expression. Filter expressions select torrents by their
+// fields, named as in the WebAPI:
+//
+//	tracker =~ "example" && ratio > 2 && seeding_time > 14d && category = "tv"
+//
+// Comparisons are =, !=, <, <=, > and >= and, for strings, =~ and !~ matching a regular
+// expression; in matches any value of a list, as in category in ["tv", "movies"].
+// Durations such as 3d or 1h30m (units s, m, h, d, w) are seconds; sizes such as 10GiB or
+// 500MB are bytes. A bool field alone means it is true. Comparisons combine with &&, ||
+// and !, and group with parentheses.
+//
+// Besides the fields of Torrent there are tag, compared with each of the tags, age, the
+// time since the torrent was added, and inactive_time, the time since its last activity.
+type Filter struct {
+	expr filterExpr
+	text string
+}
+
+// ParseFilter compiles a filter expression, see Filter.
+func ParseFilter(s string) (*Filter, error) {
+	e, err := parseFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filter{expr: e, text: s}, nil
+}
+
+// Match reports whether t matches the filter.
+func (f *Filter) Match(t Torrent) bool {
+	return f.expr.eval(&t, time.Now())
+}
+
+func (f *Filter) String() string {
+	return f.text
+}
+
+// filterStateServerFilters maps states to the server filter selecting only them.
+var filterStateServerFilters = map[string]TorrentFilter{
+	string(TorrentStateStalledUp):    TorrentFilterStalledUploading,
+	string(TorrentStateStalledDl):    TorrentFilterStalledDownloading,
+	string(TorrentStateError):        TorrentFilterError,
+	string(TorrentStateMissingFiles): TorrentFilterError,
+}
+
+// serverOptions returns the options selecting on the server a superset of the torrents
+// matching f, from the comparisons the whole expression requires to hold.
+func (f *Filter) serverOptions() TorrentFilterOptions {
+	var opts TorrentFilterOptions
+
+	var visit func(e filterExpr)
+	visit = func(e filterExpr) {
+		switch e := e.(type) {
+		case filterAnd:
+			visit(e.l)
+			visit(e.r)
+		case filterCompare:
+			switch {
+			case e.field.name == "category" && e.op == "=" && e.str != "" && opts.Category == "":
+				opts.Category = e.str
+			case e.field.name == "tag" && e.op == "=" && e.str != "" && opts.Tag == "":
+				opts.Tag = e.str
+			case e.field.name == "hash" && e.op == "=" && opts.Hashes == nil:
+				opts.Hashes = []string{e.str}
+			case e.field.name == "hash" && e.op == "in" && opts.Hashes == nil && len(e.strs) > 0:
+				opts.Hashes = e.strs
+			case e.field.name == "state" && e.op == "=" && opts.Filter == "":
+				opts.Filter = stateServerFilter([]string{e.str})
+			case e.field.name == "state" && e.op == "in" && opts.Filter == "":
+				opts.Filter = stateServerFilter(e.strs)
+			}
+		}
+	}
+	visit(f.expr)
+
+	return opts
+}
+
+// stateServerFilter returns the server filter selecting states, empty if there is none.
+func stateServerFilter(states []string) TorrentFilter {
+	var filter TorrentFilter
+	for _, state := range states {
+		f, ok := filterStateServerFilters[state]
+		if !ok || (filter != "" && f != filter) {
+			return ""
+		}
+		filter = f
+	}
+
+	return filter
+}
+
+// GetTorrentsWhere gets the torrents matching a filter expression, see GetTorrentsWhereCtx.
+func (c *Client) GetTorrentsWhere(where string) ([]Torrent, error) {
+	return c.GetTorrentsWhereCtx(context.Background(), where)
+}
+
+// GetTorrentsWhereCtx gets the torrents matching a filter expression, see Filter. The
+// category, tag, hashes and server filter the expression requires are sent to the server
+// so it returns fewer torrents; the rest is evaluated here.
+func (c *Client) GetTorrentsWhereCtx(ctx context.Context, where string) ([]Torrent, error) {
+	f, err := ParseFilter(where)
+	if err != nil {
+		return nil, err
+	}
+
+	torrents, err := c.GetTorrentsCtx(ctx, f.serverOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	matching := torrents[:0]
+	for i := range torrents {
+		if f.expr.eval(&torrents[i], now) {
+			matching = append(matching, torrents[i])
+		}
+	}
+
+	return matching, nil
+}
+
+// filterExpr is a compiled filter expression, see Filter.
+type filterExpr interface {
+	eval(t *Torrent, now time.Time) bool
+}
+
+type filterAnd struct{ l, r filterExpr }
+
+func (e filterAnd) eval(t *Torrent, now time.Time) bool { return e.l.eval(t, now) && e.r.eval(t, now) }
+
+type filterOr struct{ l, r filterExpr }
+
+func (e filterOr) eval(t *Torrent, now time.Time) bool { return e.l.eval(t, now) || e.r.eval(t, now) }
+
+type filterNot struct{ e filterExpr }
+
+func (e filterNot) eval(t *Torrent, now time.Time) bool { return !e.e.eval(t, now) }
+
+type filterKind int
+
+const (
+	filterString filterKind = iota
+	filterNumber
+	filterBool
+	filterTags
+)
+
+// filterField is a field expressions can compare. get returns a string, float64, bool or,
+// for filterTags, []string.
+type filterField struct {
+	name string
+	kind filterKind
+	get  func(t *Torrent, now time.Time) any
+}
+
+var filterFields = buildFilterFields()
+
+func buildFilterFields() map[string]filterField {
+	fields := map[string]filterField{}
+
+	typ := reflect.TypeOf(Torrent{})
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		var kind filterKind
+		switch f.Type.Kind() {
+		case reflect.String:
+			kind = filterString
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+			kind = filterNumber
+		case reflect.Bool:
+			kind = filterBool
+		default:
+			continue
+		}
+
+		index := i
+		fields[name] = filterField{name: name, kind: kind, get: func(t *Torrent, _ time.Time) any {
+			v := reflect.ValueOf(t).Elem().Field(index)
+			switch v.Kind() {
+			case reflect.String:
+				return v.String()
+			case reflect.Bool:
+				return v.Bool()
+			case reflect.Float32, reflect.Float64:
+				return v.Float()
+			default:
+				return float64(v.Int())
+			}
+		}}
+	}
+
+	fields["tag"] = filterField{name: "tag", kind: filterTags, get: func(t *Torrent, _ time.Time) any {
+		return splitTags(t.Tags)
+	}}
+	fields["age"] = filterField{name: "age", kind: filterNumber, get: func(t *Torrent, now time.Time) any {
+		return float64(now.Unix() - t.AddedOn)
+	}}
+	fields["inactive_time"] = filterField{name: "inactive_time", kind: filterNumber, get: func(t *Torrent, now time.Time) any {
+		return float64(now.Unix() - t.LastActivity)
+	}}
+
+	return fields
+}
+
+// filterCompare compares a field with a literal.
+type filterCompare struct {
+	field filterField
+	op    string
+	str   string
+	num   float64
+	b     bool
+	re    *regexp.Regexp
+
+	// strs or nums for in
+	strs []string
+	nums []float64
+}
+
+func (e filterCompare) eval(t *Torrent, now time.Time) bool {
+	switch v := e.field.get(t, now).(type) {
+	case string:
+		return e.compareString(v)
+	case float64:
+		switch e.op {
+		case "in":
+			return slices.Contains(e.nums, v)
+		case "=":
+			return v == e.num
+		case "!=":
+			return v != e.num
+		case "<":
+			return v < e.num
+		case "<=":
+			return v <= e.num
+		case ">":
+			return v > e.num
+		default:
+			return v >= e.num
+		}
+	case bool:
+		return (v == e.b) == (e.op == "=")
+	case []string:
+		// != and !~ hold if no tag matches
+		negate := e.op == "!=" || e.op == "!~"
+		for _, tag := range v {
+			if e.compareString(tag) != negate {
+				return !negate
+			}
+		}
+		return negate
+	}
+
+	return false
+}
+
+func (e filterCompare) compareString(v string) bool {
+	switch e.op {
+	case "=":
+		return v == e.str
+	case "!=":
+		return v != e.str
+	case "=~":
+		return e.re.MatchString(v)
+	case "in":
+		return slices.Contains(e.strs, v)
+	default:
+		return !e.re.MatchString(v)
+	}
+}
+
+type filterTokenKind int
+
+const (
+	tokenEOF filterTokenKind = iota
+	tokenIdent
+	tokenString
+	tokenLiteral // numbers, durations and sizes
+	tokenOp
+)
+
+type filterToken struct {
+	kind filterTokenKind
+	text string
+	pos  int
+}
+
+// filterOps are matched longest first.
+var filterOps = []string{"&&", "||", "==", "!=", "=~", "!~", "<=", ">=", "=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func lexFilter(s string) ([]filterToken, error) {
+	var tokens []filterToken
+
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, errors.New("filter: unterminated string at offset %d", i)
+			}
+
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return nil, errors.New("filter: invalid string at offset %d", i)
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenString, text: text, pos: i})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: tokenIdent, text: s[i:j], pos: i})
+			i = j
+		case unicode.IsDigit(c) || c == '-' || c == '.':
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			tokens = append(tokens, filterToken{kind: tokenLiteral, text: s[i:j], pos: i})
+			i = j
+		default:
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, errors.New("filter: unexpected %q at offset %d", c, i)
+			}
+
+			text := op
+			if op == "==" {
+				text = "="
+			}
+
+			tokens = append(tokens, filterToken{kind: tokenOp, text: text, pos: i})
+			i += len(op)
+		}
+	}
+
+	return append(tokens, filterToken{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+// parseFilter compiles a filter expression.
+func parseFilter(s string) (filterExpr, error) {
+	tokens, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &filterParser{tokens: tokens}
+
+	e, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, p.errorf(tok, "unexpected %q", tok.text)
+	}
+
+	return e, nil
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.pos]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *filterParser) accept(op string) bool {
+	if tok := p.peek(); tok.kind == tokenOp && tok.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) errorf(tok filterToken, msg string, args ...any) error {
+	if tok.kind == tokenEOF {
+		return errors.New("filter: "+msg+" at end of expression", args...)
+	}
+	return errors.New("filter: "+msg+" at offset %d", append(args, tok.pos)...)
+}
+
+func (p *filterParser) or() (filterExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("||") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = filterOr{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *filterParser) and() (filterExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.accept("&&") {
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = filterAnd{l, r}
+	}
+
+	return l, nil
+}
+
+func (p *filterParser) unary() (filterExpr, error) {
+	if p.accept("!") {
+		e, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{e}, nil
+	}
+
+	if p.accept("(") {
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf(p.peek(), "expected )")
+		}
+		return e, nil
+	}
+
+	return p.comparison()
+}
+
+func (p *filterParser) comparison() (filterExpr, error) {
+	tok := p.next()
+	if tok.kind != tokenIdent {
+		return nil, p.errorf(tok, "expected field")
+	}
+
+	field, ok := filterFields[tok.text]
+	if !ok {
+		return nil, p.errorf(tok, "unknown field %q", tok.text)
+	}
+
+	opTok := p.peek()
+	isIn := opTok.kind == tokenIdent && opTok.text == "in"
+	if !isIn && (opTok.kind != tokenOp || !isCompareOp(opTok.text)) {
+		if field.kind == filterBool {
+			return filterCompare{field: field, op: "=", b: true}, nil
+		}
+		return nil, p.errorf(opTok, "expected comparison after %v", field.name)
+	}
+	p.next()
+
+	e := filterCompare{field: field, op: opTok.text}
+
+	switch field.kind {
+	case filterString, filterTags:
+		if e.op != "=" && e.op != "!=" && e.op != "=~" && e.op != "!~" && e.op != "in" {
+			return nil, p.errorf(opTok, "%v cannot be compared with %v", field.name, e.op)
+		}
+	case filterNumber:
+		if e.op == "=~" || e.op == "!~" {
+			return nil, p.errorf(opTok, "%v cannot be compared with %v", field.name, e.op)
+		}
+	case filterBool:
+		if e.op != "=" && e.op != "!=" {
+			return nil, p.errorf(opTok, "%v cannot be compared with %v", field.name, e.op)
+		}
+	}
+
+	if isIn {
+		if !p.accept("[") {
+			return nil, p.errorf(p.peek(), "expected [ after in")
+		}
+
+		for !p.accept("]") {
+			if len(e.strs)+len(e.nums) > 0 && !p.accept(",") {
+				return nil, p.errorf(p.peek(), "expected , or ]")
+			}
+
+			val, err := p.value(field)
+			if err != nil {
+				return nil, err
+			}
+			if field.kind == filterNumber {
+				e.nums = append(e.nums, val.num)
+			} else {
+				e.strs = append(e.strs, val.str)
+			}
+		}
+
+		return e, nil
+	}
+
+	val, err := p.value(field)
+	if err != nil {
+		return nil, err
+	}
+	e.str, e.num, e.b = val.str, val.num, val.b
+
+	if e.op == "=~" || e.op == "!~" {
+		re, err := regexp.Compile(e.str)
+		if err != nil {
+			return nil, p.errorf(p.tokens[p.pos-1], "invalid regular expression: %v", err)
+		}
+		e.re = re
+	}
+
+	return e, nil
+}
+
+// value parses a literal of the kind of field. Only the matching member of the result
+// is set.
+func (p *filterParser) value(field filterField) (filterCompare, error) {
+	val := p.next()
+
+	switch field.kind {
+	case filterNumber:
+		if val.kind != tokenLiteral {
+			return filterCompare{}, p.errorf(val, "%v must be compared with a number", field.name)
+		}
+
+		n, err := parseFilterNumber(val.text)
+		if err != nil {
+			return filterCompare{}, p.errorf(val, "%v", err)
+		}
+		return filterCompare{num: n}, nil
+	case filterBool:
+		if val.kind != tokenIdent || (val.text != "true" && val.text != "false") {
+			return filterCompare{}, p.errorf(val, "%v must be compared with true or false", field.name)
+		}
+		return filterCompare{b: val.text == "true"}, nil
+	default:
+		if val.kind != tokenString {
+			return filterCompare{}, p.errorf(val, "%v must be compared with a string", field.name)
+		}
+		return filterCompare{str: val.text}, nil
+	}
+}
+
+func isCompareOp(op string) bool {
+	switch op {
+	case "=", "!=", "=~", "!~", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+var (
+	filterSizeUnits = map[string]float64{
+		"B":   1,
+		"KB":  1e3,
+		"MB":  1e6,
+		"GB":  1e9,
+		"TB":  1e12,
+		"KiB": 1 << 10,
+		"MiB": 1 << 20,
+		"GiB": 1 << 30,
+		"TiB": 1 << 40,
+	}
+
+	filterDurationUnits = map[byte]float64{
+		's': 1,
+		'm': 60,
+		'h': 60 * 60,
+		'd': 24 * 60 * 60,
+		'w': 7 * 24 * 60 * 60,
+	}
+
+	filterDurationRe     = regexp.MustCompile(`^(?:\d+(?:\.\d+)?[smhdw])+$`)
+	filterDurationPartRe = regexp.MustCompile(`(\d+(?:\.\d+)?)([smhdw])`)
+)
+
+// parseFilterNumber parses a number, a size in bytes or a duration in seconds.
+func parseFilterNumber(s string) (float64, error) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, nil
+	}
+
+	i := strings.IndexFunc(s, unicode.IsLetter)
+	if i > 0 {
+		if unit, ok := filterSizeUnits[s[i:]]; ok {
+			if n, err := strconv.ParseFloat(s[:i], 64); err == nil {
+				return n * unit, nil
+			}
+		}
+	}
+
+	if filterDurationRe.MatchString(s) {
+		var secs float64
+		for _, m := range filterDurationPartRe.FindAllStringSubmatch(s, -1) {
+			n, _ := strconv.ParseFloat(m[1], 64)
+			secs += n * filterDurationUnits[m[2][0]]
+		}
+		return secs, nil
+	}
+
+	return 0, errors.New("invalid number, size or duration %q", s)
+}
@@ -0,0 +1,173 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilter(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	torrent := Torrent{
+		Name:         "Show.S01E01.1080p",
+		Category:     "tv",
+		Tags:         "cross-seed, keep",
+		Tracker:      "https://tracker.example.org/announce",
+		Ratio:        2.5,
+		SeedingTime:  15 * 24 * 60 * 60,
+		Size:         12 << 30,
+		AddedOn:      now.Add(-30 * 24 * time.Hour).Unix(),
+		LastActivity: now.Add(-2 * time.Hour).Unix(),
+		ForceStart:   true,
+		State:        TorrentStateStalledUp,
+	}
+
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{expr: `tracker =~ "example" && ratio > 2 && seeding_time > 14d && category = "tv"`, want: true},
+		{expr: `category == "tv"`, want: true},
+		{expr: `category != "tv"`, want: false},
+		{expr: `name =~ "(?i)s01e0[1-3]"`, want: true},
+		{expr: `name !~ "2160p"`, want: true},
+		{expr: `ratio >= 2.5 && ratio <= 2.5 && ratio < 3`, want: true},
+		{expr: `size > 10GiB && size < 13GiB`, want: true},
+		{expr: `size > 13GB`, want: false},
+		{expr: `seeding_time >= 2w1d`, want: true},
+		{expr: `seeding_time > 3w`, want: false},
+		{expr: `age > 29d && inactive_time < 3h && inactive_time > 90m`, want: true},
+		{expr: `tag = "keep"`, want: true},
+		{expr: `tag != "keep"`, want: false},
+		{expr: `tag != "other"`, want: true},
+		{expr: `tag =~ "^cross"`, want: true},
+		{expr: `tag !~ "^cross"`, want: false},
+		{expr: `force_start`, want: true},
+		{expr: `!force_start`, want: false},
+		{expr: `force_start = false || auto_tmm != false`, want: false},
+		{expr: `state = "stalledUP" && !(category = "movies" || ratio < 1)`, want: true},
+		{expr: `category = "movies" || category = "tv" && ratio > 3`, want: false},
+		{expr: `(category = "movies" || category = "tv") && ratio > 2`, want: true},
+		{expr: `priority > -1`, want: true},
+		{expr: `category in ["movies", "tv"]`, want: true},
+		{expr: `category in ["movies"]`, want: false},
+		{expr: `!(category in ["movies"])`, want: true},
+		{expr: `tag in ["other", "keep"]`, want: true},
+		{expr: `tag in []`, want: false},
+		{expr: `ratio in [1, 2.5]`, want: true},
+		{expr: `size in [12GiB]`, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := parseFilter(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, f.eval(&torrent, now))
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{expr: ``, err: "expected field at end of expression"},
+		{expr: `unknown = "a"`, err: `unknown field "unknown" at offset 0`},
+		{expr: `ratio > "a"`, err: "ratio must be compared with a number at offset 8"},
+		{expr: `ratio =~ "a"`, err: "ratio cannot be compared with =~ at offset 6"},
+		{expr: `category > "a"`, err: "category cannot be compared with > at offset 9"},
+		{expr: `category = tv`, err: "category must be compared with a string at offset 11"},
+		{expr: `category`, err: "expected comparison after category at end of expression"},
+		{expr: `name =~ "("`, err: "invalid regular expression"},
+		{expr: `size > 10XB`, err: `invalid number, size or duration "10XB"`},
+		{expr: `force_start = 1`, err: "force_start must be compared with true or false"},
+		{expr: `(ratio > 1`, err: "expected ) at end of expression"},
+		{expr: `ratio > 1 ratio`, err: `unexpected "ratio" at offset 10`},
+		{expr: `name = "a`, err: "unterminated string at offset 7"},
+		{expr: `ratio > 1 & ratio < 2`, err: `unexpected '&' at offset 10`},
+		{expr: `category in "tv"`, err: "expected [ after in at offset 12"},
+		{expr: `category in ["tv" "movies"]`, err: "expected , or ] at offset 18"},
+		{expr: `category in ["tv", 1]`, err: "category must be compared with a string at offset 19"},
+		{expr: `force_start in [true]`, err: "force_start cannot be compared with in at offset 12"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseFilter(tt.expr)
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
+
+func TestFilter_serverOptions(t *testing.T) {
+	tests := []struct {
+		expr string
+		want TorrentFilterOptions
+	}{
+		{
+			expr: `category = "tv" && tag = "keep" && ratio > 1`,
+			want: TorrentFilterOptions{Category: "tv", Tag: "keep"},
+		},
+		{
+			expr: `hash in ["a", "b"] && (state = "stalledUP" && category = "tv")`,
+			want: TorrentFilterOptions{Hashes: []string{"a", "b"}, Filter: TorrentFilterStalledUploading, Category: "tv"},
+		},
+		{
+			expr: `state in ["error", "missingFiles"]`,
+			want: TorrentFilterOptions{Filter: TorrentFilterError},
+		},
+		{
+			// not required by the whole expression
+			expr: `category = "tv" || tag = "keep" || !(hash = "a")`,
+		},
+		{
+			expr: `category != "tv" && state in ["stalledUP", "uploading"] && category = ""`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			f, err := ParseFilter(tt.expr)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, tt.want, f.serverOptions())
+		})
+	}
+}
+
+func TestClient_GetTorrentsWhereCtx(t *testing.T) {
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "tv", r.URL.Query().Get("category"))
+			assert.Equal(t, "stalled_uploading", r.URL.Query().Get("filter"))
+			assert.False(t, r.URL.Query().Has("tag"))
+
+			_, _ = w.Write([]byte(`[
+				{"hash":"a","name":"A","category":"tv","state":"stalledUP","ratio":3},
+				{"hash":"b","name":"B","category":"tv","state":"stalledUP","ratio":0.5},
+				{"hash":"c","name":"C","category":"tv","state":"stalledUP","ratio":2.5}
+			]`))
+		},
+	})
+
+	torrents, err := client.GetTorrentsWhereCtx(context.Background(), `category = "tv" && state = "stalledUP" && ratio >= 2.5`)
+	require.NoError(t, err)
+
+	var hashes []string
+	for _, torrent := range torrents {
+		hashes = append(hashes, torrent.Hash)
+	}
+	assert.Equal(t, []string{"a", "c"}, hashes)
+
+	_, err = client.GetTorrentsWhereCtx(context.Background(), `ratio >`)
+	assert.ErrorContains(t, err, "filter: ratio must be compared with a number at end of expression")
+}
@@ -0,0 +1,131 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Middleware wraps the transport of a Client, e.g. to add headers or record requests.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RequestInfo describes a WebAPI request passed to a RequestHook.
+type RequestInfo struct {
+	// Endpoint is the WebAPI endpoint, e.g. "torrents/info".
+	Endpoint string
+	Method   string
+
+	// Hashes is the number of torrent hashes the request is about, or -1 for "all".
+	Hashes int
+
+	// Attempt is 0 for the request as a whole, which spans every attempt and any
+	// re-login in between, and counts from 1 for each attempt.
+	Attempt int
+}
+
+// RequestResult is the outcome of a request or attempt.
+type RequestResult struct {
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+	Duration   time.Duration
+	Err        error
+}
+
+// RequestHook observes the requests a Client makes. RequestStart is called before a
+// request and each of its attempts; the context it returns is used for them and passed to
+// the matching RequestEnd. The context of a request is the parent of its attempts and of
+// a re-login it causes, so hooks can nest spans.
+type RequestHook interface {
+	RequestStart(ctx context.Context, info RequestInfo) context.Context
+	RequestEnd(ctx context.Context, info RequestInfo, result RequestResult)
+}
+
+// RequestHookFuncs implements RequestHook with optional functions.
+type RequestHookFuncs struct {
+	Start func(ctx context.Context, info RequestInfo) context.Context
+	End   func(ctx context.Context, info RequestInfo, result RequestResult)
+}
+
+func (h RequestHookFuncs) RequestStart(ctx context.Context, info RequestInfo) context.Context {
+	if h.Start == nil {
+		return ctx
+	}
+
+	return h.Start(ctx, info)
+}
+
+func (h RequestHookFuncs) RequestEnd(ctx context.Context, info RequestInfo, result RequestResult) {
+	if h.End != nil {
+		h.End(ctx, info, result)
+	}
+}
+
+// wrapTransport applies the configured middleware, the first one outermost.
+func (c *Client) wrapTransport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	for i := len(c.cfg.Middleware) - 1; i >= 0; i-- {
+		rt = c.cfg.Middleware[i](rt)
+	}
+
+	return rt
+}
+
+func (c *Client) hooksStart(ctx context.Context, info RequestInfo) context.Context {
+	for _, h := range c.cfg.Hooks {
+		ctx = h.RequestStart(ctx, info)
+	}
+
+	return ctx
+}
+
+func (c *Client) hooksEnd(ctx context.Context, info RequestInfo, result RequestResult) {
+	for i := len(c.cfg.Hooks) - 1; i >= 0; i-- {
+		c.cfg.Hooks[i].RequestEnd(ctx, info, result)
+	}
+}
+
+// newRequestInfo describes req, whose form encoded body, if any, is body.
+func newRequestInfo(req *http.Request, body []byte) RequestInfo {
+	info := RequestInfo{
+		Endpoint: requestEndpoint(req),
+		Method:   req.Method,
+	}
+
+	params := req.URL.Query()
+	if strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			params = form
+		}
+	}
+
+	// the endpoints name their hashes differently
+	for _, key := range []string{"hashes", "hash", "id"} {
+		v := params.Get(key)
+		if v == "" {
+			continue
+		}
+
+		if v == "all" {
+			info.Hashes = -1
+		} else {
+			info.Hashes = len(strings.Split(v, "|"))
+		}
+
+		break
+	}
+
+	return info
+}
+
+func responseStatus(resp *http.Response) int {
+	if resp == nil {
+		return 0
+	}
+
+	return resp.StatusCode
+}
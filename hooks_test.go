@@ -0,0 +1,112 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Hooks(t *testing.T) {
+	var deletes atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"auth/login": func(w http.ResponseWriter, r *http.Request) {
+			http.SetCookie(w, &http.Cookie{Name: "SID", Value: "session"})
+			_, _ = w.Write([]byte("Ok."))
+		},
+		"torrents/delete": func(w http.ResponseWriter, r *http.Request) {
+			// the session expired, the client logs in again and retries
+			if deletes.Add(1) == 1 {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+		},
+	})
+	client.cfg.Username = "admin"
+	client.setCookies([]*http.Cookie{{Name: "SID", Value: "expired"}})
+
+	var (
+		mu     sync.Mutex
+		starts []RequestInfo
+		ends   []RequestResult
+	)
+
+	type parentKey struct{}
+
+	client.cfg.Hooks = []RequestHook{RequestHookFuncs{
+		Start: func(ctx context.Context, info RequestInfo) context.Context {
+			mu.Lock()
+			defer mu.Unlock()
+
+			// the login is nested in the request that caused it
+			if info.Endpoint == "auth/login" && info.Attempt == 0 {
+				parent, _ := ctx.Value(parentKey{}).(RequestInfo)
+				assert.Equal(t, "torrents/delete", parent.Endpoint)
+				assert.Equal(t, 0, parent.Attempt)
+			}
+
+			starts = append(starts, info)
+			return context.WithValue(ctx, parentKey{}, info)
+		},
+		End: func(ctx context.Context, info RequestInfo, result RequestResult) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			assert.Equal(t, info, ctx.Value(parentKey{}))
+			ends = append(ends, result)
+		},
+	}}
+
+	assert.NoError(t, client.DeleteTorrentsCtx(context.Background(), []string{"a", "b"}, false))
+
+	assert.Equal(t, []RequestInfo{
+		{Endpoint: "torrents/delete", Method: http.MethodPost, Hashes: 2, Attempt: 0},
+		{Endpoint: "torrents/delete", Method: http.MethodPost, Hashes: 2, Attempt: 1},
+		{Endpoint: "auth/login", Method: http.MethodPost, Attempt: 0},
+		{Endpoint: "auth/login", Method: http.MethodPost, Attempt: 1},
+		{Endpoint: "torrents/delete", Method: http.MethodPost, Hashes: 2, Attempt: 2},
+	}, starts)
+
+	assert.Len(t, ends, 5)
+	assert.Equal(t, http.StatusForbidden, ends[0].StatusCode)
+	// the request as a whole ends last with the final status
+	assert.Equal(t, http.StatusOK, ends[4].StatusCode)
+	assert.GreaterOrEqual(t, ends[4].Duration, ends[3].Duration)
+}
+
+func TestClient_Middleware(t *testing.T) {
+	var seen atomic.Value
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			seen.Store(r.Header.Get("X-Test"))
+			_, _ = w.Write([]byte(`[]`))
+		},
+	})
+
+	header := func(value string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				r.Header.Set("X-Test", r.Header.Get("X-Test")+value)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+
+	client.cfg.Middleware = []Middleware{header("a"), header("b")}
+	client.WithHTTPClient(&http.Client{})
+
+	_, err := client.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", seen.Load())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
@@ -105,11 +105,45 @@ func (c *Client) postBasicCtx(ctx context.Context, endpoint string, opts map[str
 	// add the content-type so qbittorrent knows what to expect
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err = c.http.Do(req)
+	// a single attempt, reported like the requests made by retryDo
+	info := newRequestInfo(req, nil)
+	ctx = c.hooksStart(ctx, info)
+
+	requestStart := time.Now()
+
+	if err := c.breaker.allow(ctx, c.probe); err != nil {
+		c.stats.request(req, time.Since(requestStart), err)
+		c.hooksEnd(ctx, info, RequestResult{Err: err})
+		return nil, errors.Wrap(err, "error making post request: %v", reqUrl)
+	}
+
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		c.stats.request(req, time.Since(requestStart), err)
+		c.hooksEnd(ctx, info, RequestResult{Err: err})
+		return nil, errors.Wrap(err, "error making post request: %v", reqUrl)
+	}
+
+	attempt := info
+	attempt.Attempt = 1
+	attemptCtx := c.hooksStart(ctx, attempt)
+
+	start := time.Now()
+	resp, err = c.http.Do(req.WithContext(attemptCtx))
+	c.breaker.record(ctx, time.Since(start), err)
+	c.stats.request(req, time.Since(requestStart), err)
+
+	result := RequestResult{StatusCode: responseStatus(resp), Duration: time.Since(start), Err: err}
+	c.hooksEnd(attemptCtx, attempt, result)
+	c.hooksEnd(ctx, info, result)
+
 	if err != nil {
+		release()
 		return nil, errors.Wrap(err, "error making post request: %v", reqUrl)
 	}
 
+	resp.Body = releaseOnClose{ReadCloser: resp.Body, release: release}
+
 	return resp, nil
 }
 
@@ -238,6 +272,8 @@ func resetBody(request *http.Request, originalBody []byte) {
 	}
 }
 
+const retryAttempts = 5
+
 func (c *Client) retryDo(ctx context.Context, req *http.Request) (*http.Response, error) {
 	var (
 		originalBody []byte
@@ -252,7 +288,17 @@ func (c *Client) retryDo(ctx context.Context, req *http.Request) (*http.Response
 		return nil, err
 	}
 
-	var resp *http.Response
+	var (
+		resp        *http.Response
+		unavailable error
+		loginFailed error
+	)
+
+	info := newRequestInfo(req, originalBody)
+	ctx = c.hooksStart(ctx, info)
+
+	start := time.Now()
+	attempts := 0
 
 	// try request and if fail run 10 retries
 	err = retry.Do(func() error {
@@ -260,11 +306,46 @@ func (c *Client) retryDo(ctx context.Context, req *http.Request) (*http.Response
 			resetBody(req, originalBody)
 		}
 
-		resp, err = c.http.Do(req)
+		// also stops retrying once the failed attempts opened the breaker
+		if err := c.breaker.allow(ctx, c.probe); err != nil {
+			unavailable = err
+			return retry.Unrecoverable(err)
+		}
+
+		release, err := c.limiter.acquire(ctx)
+		if err != nil {
+			return retry.Unrecoverable(err)
+		}
+
+		attempts++
+		attempt := info
+		attempt.Attempt = attempts
+		attemptCtx := c.hooksStart(ctx, attempt)
+
+		attemptStart := time.Now()
+		resp, err = c.http.Do(req.WithContext(attemptCtx))
+		c.breaker.record(ctx, time.Since(attemptStart), err)
+		c.hooksEnd(attemptCtx, attempt, RequestResult{StatusCode: responseStatus(resp), Duration: time.Since(attemptStart), Err: err})
+
+		if err != nil {
+			release()
+		} else {
+			resp.Body = releaseOnClose{ReadCloser: resp.Body, release: release}
+		}
 
 		if err == nil {
 			if resp.StatusCode == http.StatusForbidden {
+				// frees the request slot the login needs
+				resp.Body.Close()
+
+				c.stats.relogin()
+
 				if err := c.LoginCtx(ctx); err != nil {
+					// retrying does not fix the credentials
+					if errors.Is(err, ErrLoginFailed) {
+						loginFailed = err
+						return retry.Unrecoverable(err)
+					}
 					return errors.Wrap(err, "qbit re-login failed")
 				}
 
@@ -274,20 +355,45 @@ func (c *Client) retryDo(ctx context.Context, req *http.Request) (*http.Response
 			} else if resp.StatusCode < 500 {
 				return err
 			} else if resp.StatusCode >= 500 {
+				resp.Body.Close()
+
 				return retry.Unrecoverable(errors.New("unrecoverable status: %v", resp.StatusCode))
 			}
 		}
 
+		// a canceled request is not worth retrying, and the retries do not watch ctx
+		if ctx.Err() != nil {
+			return retry.Unrecoverable(err)
+		}
+
 		retry.Delay(time.Second * 3)
 
 		return err
 	},
-		retry.OnRetry(func(n uint, err error) { c.log.Printf("%q: attempt %d - %v\n", err, n, req.URL.String()) }),
+		retry.OnRetry(func(n uint, err error) {
+			// also called after the last attempt, which is not retried
+			if n+1 < retryAttempts {
+				c.stats.retry()
+			}
+			c.log.Warn("request attempt failed", "endpoint", info.Endpoint, "attempt", n+1, "url", req.URL.String(), "error", err)
+		}),
 		//retry.Delay(time.Second*3),
-		retry.Attempts(5),
+		retry.Attempts(retryAttempts),
 		retry.MaxJitter(time.Second*1),
 	)
 
+	c.stats.request(req, time.Since(start), err)
+	c.hooksEnd(ctx, info, RequestResult{StatusCode: responseStatus(resp), Duration: time.Since(start), Err: err})
+
+	// the retry error list does not unwrap
+	if unavailable != nil {
+		return nil, errors.Wrap(unavailable, "error making request")
+	}
+
+	if loginFailed != nil {
+		return nil, errors.Wrap(loginFailed, "qbit re-login failed")
+	}
+
 	if err != nil {
 		return nil, errors.Wrap(err, "error making request")
 	}
@@ -0,0 +1,238 @@
+package qbittorrent
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+type indexKind int
+
+const (
+	indexInfohash indexKind = iota
+	indexTrackerURL
+	indexTrackerHost
+	indexTag
+	indexCategory
+	indexContentPath
+	indexName
+)
+
+// torrentIndex maps keys derived from torrents to their hashes. It is kept up to date
+// from maindata deltas, re-indexing only the torrents a delta touches.
+type torrentIndex struct {
+	sets map[indexKind]map[string]map[string]struct{}
+
+	// keys each hash is currently indexed under, to remove it again
+	keys map[string]map[indexKind][]string
+
+	// tracker urls as last sent in maindata, and the reverse for each hash
+	trackers map[string][]string
+	hashURLs map[string]map[string]struct{}
+}
+
+func newTorrentIndex() *torrentIndex {
+	return &torrentIndex{
+		sets:     make(map[indexKind]map[string]map[string]struct{}),
+		keys:     make(map[string]map[indexKind][]string),
+		trackers: make(map[string][]string),
+		hashURLs: make(map[string]map[string]struct{}),
+	}
+}
+
+// update re-indexes what delta changed. data must already have delta applied.
+func (x *torrentIndex) update(data *MainData, delta *mainDataDelta) {
+	if delta.FullUpdate {
+		*x = *newTorrentIndex()
+
+		for tracker, hashes := range data.Trackers {
+			x.setTracker(tracker, hashes)
+		}
+
+		for hash := range data.Torrents {
+			x.reindex(data, hash)
+		}
+
+		return
+	}
+
+	dirty := make(map[string]struct{}, len(delta.Torrents)+len(delta.TorrentsRemoved))
+	for hash := range delta.Torrents {
+		dirty[hash] = struct{}{}
+	}
+	for _, hash := range delta.TorrentsRemoved {
+		dirty[hash] = struct{}{}
+	}
+
+	// a tracker carries the full list of its hashes, so both the old and new ones change
+	for tracker, hashes := range delta.Trackers {
+		for _, hash := range x.trackers[tracker] {
+			dirty[hash] = struct{}{}
+		}
+		for _, hash := range hashes {
+			dirty[hash] = struct{}{}
+		}
+
+		x.setTracker(tracker, hashes)
+	}
+
+	for _, tracker := range delta.TrackersRemoved {
+		for _, hash := range x.trackers[tracker] {
+			dirty[hash] = struct{}{}
+		}
+
+		x.setTracker(tracker, nil)
+	}
+
+	for hash := range dirty {
+		x.reindex(data, hash)
+	}
+}
+
+func (x *torrentIndex) setTracker(tracker string, hashes []string) {
+	for _, hash := range x.trackers[tracker] {
+		delete(x.hashURLs[hash], tracker)
+		if len(x.hashURLs[hash]) == 0 {
+			delete(x.hashURLs, hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		delete(x.trackers, tracker)
+		return
+	}
+
+	x.trackers[tracker] = slices.Clone(hashes)
+	for _, hash := range hashes {
+		if x.hashURLs[hash] == nil {
+			x.hashURLs[hash] = make(map[string]struct{})
+		}
+		x.hashURLs[hash][tracker] = struct{}{}
+	}
+}
+
+func (x *torrentIndex) reindex(data *MainData, hash string) {
+	for kind, keys := range x.keys[hash] {
+		for _, key := range keys {
+			delete(x.sets[kind][key], hash)
+			if len(x.sets[kind][key]) == 0 {
+				delete(x.sets[kind], key)
+			}
+		}
+
+		if len(x.sets[kind]) == 0 {
+			delete(x.sets, kind)
+		}
+	}
+
+	delete(x.keys, hash)
+
+	t, ok := data.Torrents[hash]
+	if !ok {
+		return
+	}
+
+	keys := x.torrentKeys(t)
+	for kind, keys := range keys {
+		if x.sets[kind] == nil {
+			x.sets[kind] = make(map[string]map[string]struct{})
+		}
+
+		for _, key := range keys {
+			if x.sets[kind][key] == nil {
+				x.sets[kind][key] = make(map[string]struct{})
+			}
+			x.sets[kind][key][hash] = struct{}{}
+		}
+	}
+
+	x.keys[hash] = keys
+}
+
+func (x *torrentIndex) torrentKeys(t Torrent) map[indexKind][]string {
+	keys := make(map[indexKind][]string)
+
+	for _, h := range []string{t.Hash, t.InfohashV1, t.InfohashV2} {
+		if h != "" {
+			keys[indexInfohash] = append(keys[indexInfohash], strings.ToLower(h))
+		}
+	}
+
+	var trackers []string
+	if t.Tracker != "" {
+		trackers = append(trackers, t.Tracker)
+	}
+	for tracker := range x.hashURLs[t.Hash] {
+		trackers = append(trackers, tracker)
+	}
+
+	for _, tracker := range trackers {
+		keys[indexTrackerURL] = append(keys[indexTrackerURL], tracker)
+		if host := trackerHost(tracker); host != "" {
+			keys[indexTrackerHost] = append(keys[indexTrackerHost], host)
+		}
+	}
+
+	for _, tag := range splitTags(t.Tags) {
+		if tag != "" {
+			keys[indexTag] = append(keys[indexTag], tag)
+		}
+	}
+
+	keys[indexCategory] = []string{t.Category}
+
+	if p := cleanContentPath(t.ContentPath); p != "" {
+		keys[indexContentPath] = []string{p}
+	}
+
+	if t.Name != "" {
+		keys[indexName] = []string{t.Name}
+	}
+
+	for kind := range keys {
+		slices.Sort(keys[kind])
+		keys[kind] = slices.Compact(keys[kind])
+	}
+
+	return keys
+}
+
+// lookup returns the hashes indexed under key.
+func (x *torrentIndex) lookup(kind indexKind, key string) []string {
+	switch kind {
+	case indexInfohash, indexTrackerHost:
+		key = strings.ToLower(key)
+	case indexContentPath:
+		key = cleanContentPath(key)
+	}
+
+	set := x.sets[kind][key]
+	hashes := make([]string, 0, len(set))
+	for hash := range set {
+		hashes = append(hashes, hash)
+	}
+
+	slices.Sort(hashes)
+	return hashes
+}
+
+// trackerHost returns the lower case host of a tracker url without its port.
+func trackerHost(tracker string) string {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
+
+// cleanContentPath strips trailing separators so a path matches with and without them.
+func cleanContentPath(p string) string {
+	trimmed := strings.TrimRight(p, `/\`)
+	if trimmed == "" {
+		return p
+	}
+
+	return trimmed
+}
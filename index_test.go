@@ -0,0 +1,65 @@
+package qbittorrent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTorrentIndex(t *testing.T) {
+	data := MainData{}
+	index := newTorrentIndex()
+
+	apply := func(s string) {
+		t.Helper()
+
+		delta := decodeDelta(t, s)
+		assert.NoError(t, data.apply(delta))
+		index.update(&data, delta)
+
+		// the incremental index always matches one built from scratch
+		rebuilt := newTorrentIndex()
+		rebuilt.update(&data, &mainDataDelta{FullUpdate: true})
+		assert.Equal(t, rebuilt.sets, index.sets)
+	}
+
+	apply(`{"rid":1,"full_update":true,
+		"torrents":{
+			"a":{"name":"Show.S01","infohash_v1":"a","infohash_v2":"A2","category":"tv","tags":"x, y","content_path":"/data/Show.S01/","tracker":"https://t1.example:443/announce"},
+			"b":{"name":"Movie","infohash_v1":"b","category":"movies","tags":"y","content_path":"/data/Movie.mkv"}
+		},
+		"trackers":{"https://t1.example:443/announce":["a"],"http://t2.example/announce":["a","b"]}}`)
+
+	assert.Equal(t, []string{"a"}, index.lookup(indexInfohash, "a2"))
+	assert.Equal(t, []string{"a"}, index.lookup(indexTrackerHost, "T1.example"))
+	assert.Equal(t, []string{"a", "b"}, index.lookup(indexTrackerHost, "t2.example"))
+	assert.Equal(t, []string{"a", "b"}, index.lookup(indexTag, "y"))
+	assert.Equal(t, []string{"b"}, index.lookup(indexCategory, "movies"))
+	assert.Equal(t, []string{"a"}, index.lookup(indexContentPath, "/data/Show.S01"))
+	assert.Equal(t, []string{"b"}, index.lookup(indexName, "Movie"))
+
+	// only changed fields arrive, the rest of the keys must stay
+	apply(`{"rid":2,"torrents":{"a":{"category":"archive","tags":"x"}}}`)
+	assert.Empty(t, index.lookup(indexCategory, "tv"))
+	assert.Equal(t, []string{"a"}, index.lookup(indexCategory, "archive"))
+	assert.Equal(t, []string{"b"}, index.lookup(indexTag, "y"))
+	assert.Equal(t, []string{"a"}, index.lookup(indexName, "Show.S01"))
+
+	// tracker changes without torrent changes
+	apply(`{"rid":3,"trackers":{"http://t2.example/announce":["b"]},"trackers_removed":["https://t1.example:443/announce"]}`)
+	assert.Equal(t, []string{"b"}, index.lookup(indexTrackerHost, "t2.example"))
+	// the torrent itself still reports t1 as its current tracker
+	assert.Equal(t, []string{"a"}, index.lookup(indexTrackerHost, "t1.example"))
+
+	apply(`{"rid":4,"torrents":{"a":{"tracker":""}}}`)
+	assert.Empty(t, index.lookup(indexTrackerHost, "t1.example"))
+
+	apply(`{"rid":5,"torrents_removed":["b"],"trackers_removed":["http://t2.example/announce"]}`)
+	assert.Empty(t, index.lookup(indexInfohash, "b"))
+	assert.Empty(t, index.lookup(indexContentPath, "/data/Movie.mkv"))
+	assert.Empty(t, index.lookup(indexTrackerHost, "t2.example"))
+
+	apply(`{"rid":1,"full_update":true,"torrents":{"c":{"name":"c"}}}`)
+	assert.Empty(t, index.lookup(indexName, "Show.S01"))
+	assert.Equal(t, []string{"c"}, index.lookup(indexCategory, ""))
+}
@@ -0,0 +1,105 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"net/http"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// DefaultTorrentsPageSize is the page size TorrentsIterCtx uses when none is given.
+const DefaultTorrentsPageSize = 500
+
+// TorrentsIter iterate over torrents page by page, see TorrentsIterCtx.
+func (c *Client) TorrentsIter(o TorrentFilterOptions, pageSize int) iter.Seq2[Torrent, error] {
+	return c.TorrentsIterCtx(context.Background(), o, pageSize)
+}
+
+// TorrentsIterCtx iterate over torrents matching o, requesting pageSize torrents at a
+// time, so memory use does not grow with the number of torrents. Each page is read
+// completely before its torrents are yielded, so the loop body can make requests of its
+// own without waiting for a request slot of Config.MaxConcurrentRequests.
+//
+// Pages are requested with Limit and Offset. Unless o.Sort is set, torrents are sorted by
+// hash so the order is stable between pages; torrents added or removed while iterating
+// can still shift pages and be skipped or seen twice. o.Limit and o.Offset bound the
+// whole iteration. An error ends the iteration.
+//
+//	for torrent, err := range client.TorrentsIterCtx(ctx, qbittorrent.TorrentFilterOptions{}, 0) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) TorrentsIterCtx(ctx context.Context, o TorrentFilterOptions, pageSize int) iter.Seq2[Torrent, error] {
+	if pageSize <= 0 {
+		pageSize = DefaultTorrentsPageSize
+	}
+
+	if o.Sort == "" {
+		o.Sort = "hash"
+	}
+
+	return func(yield func(Torrent, error) bool) {
+		offset := o.Offset
+		remaining := o.Limit
+
+		for {
+			limit := pageSize
+			if o.Limit > 0 {
+				if remaining <= 0 {
+					return
+				}
+				limit = min(limit, remaining)
+			}
+
+			page := o
+			page.Limit = limit
+			page.Offset = offset
+
+			torrents, err := c.getTorrentsPage(ctx, page)
+			if err != nil {
+				yield(Torrent{}, err)
+				return
+			}
+
+			for _, torrent := range torrents {
+				if !yield(torrent, nil) {
+					return
+				}
+			}
+
+			n := len(torrents)
+			if n < limit {
+				return
+			}
+
+			offset += n
+			remaining -= n
+		}
+	}
+}
+
+// getTorrentsPage requests a single page and decodes it. The response body is closed,
+// and its request slot released, before the page is returned.
+func (c *Client) getTorrentsPage(ctx context.Context, o TorrentFilterOptions) ([]Torrent, error) {
+	resp, err := c.getCtx(ctx, "torrents/info", o.params())
+	if err != nil {
+		return nil, errors.Wrap(err, "get torrents error")
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("could not get torrents, unexpected status: %v", resp.StatusCode)
+	}
+
+	var torrents []Torrent
+	if err := json.NewDecoder(resp.Body).Decode(&torrents); err != nil {
+		return nil, errors.Wrap(err, "could not decode torrents")
+	}
+
+	return torrents, nil
+}
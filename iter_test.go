@@ -0,0 +1,142 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeTorrentsInfo serves n torrents from torrents/info, honoring limit and offset.
+func fakeTorrentsInfo(t *testing.T, n int, requests *atomic.Int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		assert.Equal(t, "hash", r.URL.Query().Get("sort"))
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		page := make([]Torrent, 0)
+		for i := offset; i < n && (limit == 0 || i < offset+limit); i++ {
+			page = append(page, Torrent{Hash: fmt.Sprintf("%040d", i)})
+		}
+
+		_ = json.NewEncoder(w).Encode(page)
+	}
+}
+
+func TestClient_TorrentsIterCtx(t *testing.T) {
+	tests := []struct {
+		name         string
+		torrents     int
+		pageSize     int
+		opts         TorrentFilterOptions
+		wantFirst    string
+		wantCount    int
+		wantRequests int32
+	}{
+		{name: "exact_pages", torrents: 10, pageSize: 5, wantCount: 10, wantRequests: 3, wantFirst: fmt.Sprintf("%040d", 0)},
+		{name: "partial_last_page", torrents: 11, pageSize: 5, wantCount: 11, wantRequests: 3, wantFirst: fmt.Sprintf("%040d", 0)},
+		{name: "empty", torrents: 0, pageSize: 5, wantCount: 0, wantRequests: 1},
+		{name: "limit_and_offset", torrents: 20, pageSize: 4, opts: TorrentFilterOptions{Offset: 3, Limit: 6}, wantCount: 6, wantRequests: 2, wantFirst: fmt.Sprintf("%040d", 3)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+				"torrents/info": fakeTorrentsInfo(t, tt.torrents, &requests),
+			})
+
+			var hashes []string
+			for torrent, err := range client.TorrentsIterCtx(context.Background(), tt.opts, tt.pageSize) {
+				assert.NoError(t, err)
+				hashes = append(hashes, torrent.Hash)
+			}
+
+			assert.Len(t, hashes, tt.wantCount)
+			assert.Equal(t, tt.wantRequests, requests.Load())
+			if tt.wantCount > 0 {
+				assert.Equal(t, tt.wantFirst, hashes[0])
+			}
+		})
+	}
+}
+
+func TestClient_TorrentsIterCtx_Break(t *testing.T) {
+	var requests atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": fakeTorrentsInfo(t, 100, &requests),
+	})
+
+	seen := 0
+	for _, err := range client.TorrentsIterCtx(context.Background(), TorrentFilterOptions{}, 10) {
+		assert.NoError(t, err)
+		if seen++; seen == 15 {
+			break
+		}
+	}
+
+	assert.Equal(t, 15, seen)
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestClient_GetTorrentsCountCtx(t *testing.T) {
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/count": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "tv", r.URL.Query().Get("category"))
+			assert.Empty(t, r.URL.Query().Get("limit"))
+			_, _ = w.Write([]byte("42"))
+		},
+	})
+
+	count, err := client.GetTorrentsCountCtx(context.Background(), TorrentFilterOptions{Category: "tv", Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 42, count)
+}
+
+func TestClient_GetTorrentsCountCtx_Fallback(t *testing.T) {
+	var requests atomic.Int32
+
+	client := newTestClient(t, "2.11.2", map[string]http.HandlerFunc{
+		"torrents/info": fakeTorrentsInfo(t, 1234, &requests),
+	})
+
+	count, err := client.GetTorrentsCountCtx(context.Background(), TorrentFilterOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1234, count)
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestClient_TorrentsIterCtx_NestedRequests(t *testing.T) {
+	var requests, stops atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": fakeTorrentsInfo(t, 5, &requests),
+		"torrents/stop": func(w http.ResponseWriter, r *http.Request) {
+			stops.Add(1)
+		},
+	})
+	client.limiter = newLimiter(0, 0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// with a single request slot, requests in the loop body must not wait for the page
+	for torrent, err := range client.TorrentsIterCtx(ctx, TorrentFilterOptions{}, 2) {
+		if !assert.NoError(t, err) {
+			break
+		}
+		assert.NoError(t, client.StopCtx(ctx, []string{torrent.Hash}))
+	}
+
+	assert.Equal(t, int32(5), stops.Load())
+	assert.Equal(t, 0, client.limiter.active)
+}
@@ -0,0 +1,206 @@
+package qbittorrent
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Priority orders requests waiting for Config.RateLimit or Config.MaxConcurrentRequests.
+// Waiting requests of a higher priority are sent first, requests of the same priority in
+// the order they were made.
+type Priority int
+
+const (
+	PriorityLow Priority = iota - 1
+	PriorityNormal
+	PriorityHigh
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose requests are sent with priority p. Requests
+// without one have PriorityNormal; bulk jobs should use PriorityLow so that interactive
+// calls are not stuck behind them.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return max(PriorityLow, min(PriorityHigh, p))
+	}
+
+	return PriorityNormal
+}
+
+// limiter is a token bucket combined with a cap on the requests in flight. Requests wait
+// in one queue per priority.
+type limiter struct {
+	mu sync.Mutex
+
+	rate   float64 // tokens per second, 0 for unlimited
+	burst  float64
+	tokens float64
+	last   time.Time
+	timer  *time.Timer
+
+	maxActive int // 0 for unlimited
+	active    int
+
+	// indexed by priority - PriorityLow
+	queues [3]*list.List
+}
+
+type limiterWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+// newLimiter returns nil if neither a rate nor a concurrency limit is set.
+func newLimiter(rate float64, burst int, maxActive int) *limiter {
+	if rate <= 0 && maxActive <= 0 {
+		return nil
+	}
+
+	if burst <= 0 {
+		burst = 1
+	}
+
+	l := &limiter{
+		rate:      rate,
+		burst:     float64(burst),
+		tokens:    float64(burst),
+		last:      time.Now(),
+		maxActive: maxActive,
+	}
+
+	for i := range l.queues {
+		l.queues[i] = list.New()
+	}
+
+	return l
+}
+
+// acquire waits until a request may be sent and returns the function to call once it is
+// done. A nil limiter never waits.
+func (l *limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	w := &limiterWaiter{ready: make(chan struct{})}
+
+	l.mu.Lock()
+	e := l.queues[priorityFrom(ctx)-PriorityLow].PushBack(w)
+	l.dispatch()
+	l.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return l.releaseFunc(), nil
+	case <-ctx.Done():
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if w.granted {
+			// granted while giving up, hand the slot to the next one
+			l.active--
+			l.dispatch()
+		} else {
+			l.queues[priorityFrom(ctx)-PriorityLow].Remove(e)
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
+func (l *limiter) releaseFunc() func() {
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+
+			l.active--
+			l.dispatch()
+		})
+	}
+}
+
+// dispatch grants waiters, highest priority first, while limits allow. l.mu must be held.
+func (l *limiter) dispatch() {
+	for {
+		var (
+			queue *list.List
+			front *list.Element
+		)
+
+		for i := len(l.queues) - 1; i >= 0; i-- {
+			if front = l.queues[i].Front(); front != nil {
+				queue = l.queues[i]
+				break
+			}
+		}
+
+		if front == nil {
+			return
+		}
+
+		if l.maxActive > 0 && l.active >= l.maxActive {
+			// a release dispatches again
+			return
+		}
+
+		if l.rate > 0 {
+			now := time.Now()
+			l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+			l.last = now
+
+			if l.tokens < 1 {
+				l.wakeAfter(time.Duration((1 - l.tokens) / l.rate * float64(time.Second)))
+				return
+			}
+
+			l.tokens--
+		}
+
+		l.active++
+		queue.Remove(front)
+
+		w := front.Value.(*limiterWaiter)
+		w.granted = true
+		close(w.ready)
+	}
+}
+
+// wakeAfter dispatches again once the next token is available. l.mu must be held.
+func (l *limiter) wakeAfter(d time.Duration) {
+	if l.timer != nil {
+		return
+	}
+
+	l.timer = time.AfterFunc(d, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		l.timer = nil
+		l.dispatch()
+	})
+}
+
+// releaseOnClose calls release once the body is closed, so a request counts as in
+// flight until its response is read.
+type releaseOnClose struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
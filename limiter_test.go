@@ -0,0 +1,166 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Priority(t *testing.T) {
+	l := newLimiter(0, 0, 1)
+	ctx := context.Background()
+
+	release, err := l.acquire(ctx)
+	assert.NoError(t, err)
+
+	var (
+		mu    sync.Mutex
+		order []string
+		wg    sync.WaitGroup
+	)
+
+	wait := func(name string, p Priority) {
+		defer wg.Done()
+
+		done, err := l.acquire(WithPriority(ctx, p))
+		assert.NoError(t, err)
+
+		mu.Lock()
+		order = append(order, name)
+		mu.Unlock()
+
+		done()
+	}
+
+	// queue the low priority ones first, one at a time so their order is known
+	for i, w := range []struct {
+		name string
+		p    Priority
+	}{
+		{"low1", PriorityLow}, {"low2", PriorityLow}, {"normal", PriorityNormal}, {"high", PriorityHigh},
+	} {
+		wg.Add(1)
+		go wait(w.name, w.p)
+
+		assert.Eventually(t, func() bool {
+			return queued(l) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	release()
+	wg.Wait()
+
+	assert.Equal(t, []string{"high", "normal", "low1", "low2"}, order)
+}
+
+func queued(l *limiter) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := 0
+	for _, q := range l.queues {
+		n += q.Len()
+	}
+
+	return n
+}
+
+func TestLimiter_Cancel(t *testing.T) {
+	l := newLimiter(0, 0, 1)
+
+	release, err := l.acquire(context.Background())
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = l.acquire(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the cancelled waiter does not hold the slot
+	release()
+	release, err = l.acquire(context.Background())
+	assert.NoError(t, err)
+	release()
+}
+
+func TestLimiter_Rate(t *testing.T) {
+	l := newLimiter(50, 1, 0)
+
+	start := time.Now()
+	for i := 0; i < 6; i++ {
+		release, err := l.acquire(context.Background())
+		assert.NoError(t, err)
+		release()
+	}
+
+	// the first token is there already, the other 5 take 20ms each
+	assert.GreaterOrEqual(t, time.Since(start), 90*time.Millisecond)
+}
+
+func TestClient_MaxConcurrentRequests(t *testing.T) {
+	var active, peak atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			n := active.Add(1)
+			defer active.Add(-1)
+
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+
+			time.Sleep(5 * time.Millisecond)
+			_, _ = w.Write([]byte(`[]`))
+		},
+	})
+	client.limiter = newLimiter(0, 0, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := client.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+			assert.NoError(t, err)
+		}()
+	}
+
+	wg.Wait()
+	assert.LessOrEqual(t, peak.Load(), int32(2))
+	assert.Equal(t, 0, client.limiter.active)
+}
+
+func TestClient_CanceledRequest(t *testing.T) {
+	var requests atomic.Int32
+	entered := make(chan struct{})
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				close(entered)
+			}
+			<-r.Context().Done()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-entered
+		cancel()
+	}()
+
+	// a canceled request is not retried
+	_, err := client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	assert.Error(t, err)
+	assert.Equal(t, int32(1), requests.Load())
+}
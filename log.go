@@ -0,0 +1,148 @@
+package qbittorrent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// newLogger builds the logger of a client from its config. Everything logged through it
+// is redacted, see redact.
+func newLogger(cfg Config) *slog.Logger {
+	var h slog.Handler = slog.DiscardHandler
+
+	switch {
+	case cfg.Logger != nil:
+		h = cfg.Logger.Handler()
+	case cfg.Log != nil:
+		h = newLogLoggerHandler(cfg.Log)
+	}
+
+	return slog.New(redactHandler{next: h})
+}
+
+// newLogLoggerHandler adapts a *log.Logger, which adds its own prefix and time, to slog.
+// All levels are logged, as they were before slog.
+func newLogLoggerHandler(l *log.Logger) slog.Handler {
+	return slog.NewTextHandler(logLoggerWriter{l}, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	})
+}
+
+type logLoggerWriter struct {
+	l *log.Logger
+}
+
+func (w logLoggerWriter) Write(p []byte) (int, error) {
+	w.l.Print(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// redactHandler redacts the message and attributes of every record before passing it on.
+type redactHandler struct {
+	next slog.Handler
+}
+
+func (h redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, redact(r.Message), r.PC)
+
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	return h.next.Handle(ctx, out)
+}
+
+func (h redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redactedAttrs = append(redactedAttrs, redactAttr(a))
+	}
+
+	return redactHandler{next: h.next.WithAttrs(redactedAttrs)}
+}
+
+func (h redactHandler) WithGroup(name string) slog.Handler {
+	return redactHandler{next: h.next.WithGroup(name)}
+}
+
+// sensitiveKeys are attribute keys whose values are never logged.
+var sensitiveKeys = map[string]struct{}{
+	"password":      {},
+	"pass":          {},
+	"passkey":       {},
+	"sid":           {},
+	"cookie":        {},
+	"token":         {},
+	"authorization": {},
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, redact(a.Value.String()))
+	case slog.KindGroup:
+		attrs := a.Value.Group()
+		redactedAttrs := make([]any, 0, len(attrs))
+		for _, ga := range attrs {
+			redactedAttrs = append(redactedAttrs, redactAttr(ga))
+		}
+		return slog.Group(a.Key, redactedAttrs...)
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, redact(v.String()))
+		}
+	}
+
+	return a
+}
+
+var redactions = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// password in the userinfo of a url
+	{regexp.MustCompile(`(://[^:/@\s]+:)[^@/\s]+@`), "${1}" + redacted + "@"},
+	// credentials in query strings and form bodies
+	{regexp.MustCompile(`(?i)((?:^|[?&\s])(?:passkey|pass|password|apikey|api_key|authkey|auth|token|torrent_pass|sid)=)[^&;\s"]+`), "${1}" + redacted},
+	// the session cookie
+	{regexp.MustCompile(`\bSID=[^;&\s"]+`), "SID=" + redacted},
+	// passkeys in tracker paths, e.g. /<passkey>/announce or /announce/<passkey>
+	{regexp.MustCompile(`/[A-Za-z0-9]{16,}/announce`), "/" + redacted + "/announce"},
+	{regexp.MustCompile(`/announce/[A-Za-z0-9]{16,}`), "/announce/" + redacted},
+}
+
+// redact removes passwords, session ids and tracker passkeys from s.
+func redact(s string) string {
+	for _, r := range redactions {
+		s = r.re.ReplaceAllString(s, r.repl)
+	}
+
+	return s
+}
@@ -0,0 +1,358 @@
+// Package magnet parses and builds magnet URIs (BEP 9, BEP 53).
+package magnet
+
+import (
+	"encoding/base32"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+var (
+	ErrInvalidMagnet   = errors.Sentinel("invalid magnet uri")
+	ErrMissingInfoHash = errors.Sentinel("magnet uri has no infohash")
+)
+
+const (
+	scheme = "magnet:"
+
+	btihPrefix = "urn:btih:"
+	btmhPrefix = "urn:btmh:"
+
+	// multihash prefix for a 32 byte sha2-256 digest
+	sha256Multihash = "1220"
+)
+
+// Magnet is a parsed magnet URI.
+type Magnet struct {
+	// InfoHashV1 is the lowercase hex SHA-1 infohash.
+	InfoHashV1 string
+
+	// InfoHashV2 is the lowercase hex SHA-256 infohash, without the multihash prefix.
+	InfoHashV2 string
+
+	DisplayName string
+	Trackers    []string
+	WebSeeds    []string
+	ExactLength int64
+
+	// SelectOnly holds the file indexes of the so parameter (BEP 53).
+	SelectOnly []int
+
+	// Params holds the parameters that are not parsed into fields.
+	Params url.Values
+}
+
+// IsMagnet reports whether uri looks like a magnet URI.
+func IsMagnet(uri string) bool {
+	return len(uri) >= len(scheme) && strings.EqualFold(uri[:len(scheme)], scheme)
+}
+
+// Parse parses a magnet URI. Infohashes are normalized to lowercase hex.
+func Parse(uri string) (*Magnet, error) {
+	if !IsMagnet(uri) {
+		return nil, errors.Wrap(ErrInvalidMagnet, "missing magnet scheme")
+	}
+
+	// some clients separate parameters with ';', which url.ParseQuery rejects
+	rawQuery := strings.ReplaceAll(strings.TrimPrefix(uri[len(scheme):], "?"), ";", "&")
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidMagnet, "could not parse query: %v", err)
+	}
+
+	m := &Magnet{}
+
+	// BEP 9 allows numbered parameters such as xt.1 and tr.2, keep them in order
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		base, _, _ := strings.Cut(key, ".")
+
+		for _, value := range query[key] {
+			switch base {
+			case "xt":
+				if err := m.parseExactTopic(value); err != nil {
+					return nil, err
+				}
+			case "dn":
+				m.DisplayName = value
+			case "tr":
+				m.Trackers = append(m.Trackers, value)
+			case "ws":
+				m.WebSeeds = append(m.WebSeeds, value)
+			case "xl":
+				n, err := strconv.ParseInt(value, 10, 64)
+				if err != nil || n < 0 {
+					return nil, errors.Wrap(ErrInvalidMagnet, "invalid xl: %q", value)
+				}
+				m.ExactLength = n
+			case "so":
+				so, err := parseSelectOnly(value)
+				if err != nil {
+					return nil, err
+				}
+				m.SelectOnly = append(m.SelectOnly, so...)
+			default:
+				if m.Params == nil {
+					m.Params = url.Values{}
+				}
+				m.Params.Add(key, value)
+			}
+		}
+	}
+
+	if m.InfoHashV1 == "" && m.InfoHashV2 == "" {
+		return nil, ErrMissingInfoHash
+	}
+
+	return m, nil
+}
+
+func (m *Magnet) parseExactTopic(xt string) error {
+	switch {
+	case hasPrefixFold(xt, btihPrefix):
+		hash, err := normalizeBTIH(xt[len(btihPrefix):])
+		if err != nil {
+			return err
+		}
+		m.InfoHashV1 = hash
+	case hasPrefixFold(xt, btmhPrefix):
+		mh := strings.ToLower(xt[len(btmhPrefix):])
+		if !strings.HasPrefix(mh, sha256Multihash) || !isHex(mh[len(sha256Multihash):], 64) {
+			return errors.Wrap(ErrInvalidMagnet, "unsupported btmh: %q", mh)
+		}
+		m.InfoHashV2 = mh[len(sha256Multihash):]
+	default:
+		// other networks (ed2k, sha1 of files) are kept untouched
+		if m.Params == nil {
+			m.Params = url.Values{}
+		}
+		m.Params.Add("xt", xt)
+	}
+
+	return nil
+}
+
+// normalizeBTIH converts a hex or base32 v1 infohash to lowercase hex.
+func normalizeBTIH(hash string) (string, error) {
+	switch len(hash) {
+	case 40:
+		if !isHex(hash, 40) {
+			return "", errors.Wrap(ErrInvalidMagnet, "invalid hex btih: %q", hash)
+		}
+		return strings.ToLower(hash), nil
+	case 32:
+		b, err := base32.StdEncoding.DecodeString(strings.ToUpper(hash))
+		if err != nil {
+			return "", errors.Wrap(ErrInvalidMagnet, "invalid base32 btih: %q", hash)
+		}
+		return hex.EncodeToString(b), nil
+	default:
+		return "", errors.Wrap(ErrInvalidMagnet, "invalid btih length %d", len(hash))
+	}
+}
+
+// parseSelectOnly parses a BEP 53 file selection such as "0,2,4-6".
+func parseSelectOnly(so string) ([]int, error) {
+	var indexes []int
+
+	for _, part := range strings.Split(so, ",") {
+		if part == "" {
+			continue
+		}
+
+		from, to, isRange := strings.Cut(part, "-")
+
+		start, err := strconv.Atoi(from)
+		if err != nil || start < 0 {
+			return nil, errors.Wrap(ErrInvalidMagnet, "invalid so: %q", so)
+		}
+
+		end := start
+		if isRange {
+			end, err = strconv.Atoi(to)
+			if err != nil || end < start {
+				return nil, errors.Wrap(ErrInvalidMagnet, "invalid so: %q", so)
+			}
+		}
+
+		for i := start; i <= end; i++ {
+			indexes = append(indexes, i)
+		}
+	}
+
+	return indexes, nil
+}
+
+// formatSelectOnly formats file indexes, collapsing consecutive runs into ranges.
+func formatSelectOnly(indexes []int) string {
+	sorted := append([]int(nil), indexes...)
+	sort.Ints(sorted)
+
+	var parts []string
+	for i := 0; i < len(sorted); {
+		j := i
+		for j+1 < len(sorted) && sorted[j+1] <= sorted[j]+1 {
+			j++
+		}
+
+		if sorted[i] == sorted[j] {
+			parts = append(parts, strconv.Itoa(sorted[i]))
+		} else {
+			parts = append(parts, strconv.Itoa(sorted[i])+"-"+strconv.Itoa(sorted[j]))
+		}
+
+		i = j + 1
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Hash returns the id qBittorrent uses for the torrent: the v1 infohash if there is one,
+// otherwise the v2 infohash truncated to 40 characters.
+func (m *Magnet) Hash() string {
+	if m.InfoHashV1 != "" {
+		return m.InfoHashV1
+	}
+
+	if len(m.InfoHashV2) >= 40 {
+		return m.InfoHashV2[:40]
+	}
+
+	return ""
+}
+
+// Matches reports whether hash, as found in Torrent.Hash, Torrent.InfohashV1 or
+// Torrent.InfohashV2, refers to the same torrent as the magnet.
+func (m *Magnet) Matches(hash string) bool {
+	hash = strings.ToLower(hash)
+	if hash == "" {
+		return false
+	}
+
+	if hash == m.InfoHashV1 || hash == m.InfoHashV2 {
+		return true
+	}
+
+	// qBittorrent identifies v2 only torrents by their truncated v2 infohash
+	return len(hash) == 40 && m.InfoHashV2 != "" && strings.HasPrefix(m.InfoHashV2, hash)
+}
+
+// String builds the magnet URI.
+func (m *Magnet) String() string {
+	var params []string
+
+	if m.InfoHashV1 != "" {
+		params = append(params, "xt="+btihPrefix+m.InfoHashV1)
+	}
+	if m.InfoHashV2 != "" {
+		params = append(params, "xt="+btmhPrefix+sha256Multihash+m.InfoHashV2)
+	}
+	if m.DisplayName != "" {
+		params = append(params, "dn="+url.QueryEscape(m.DisplayName))
+	}
+	if m.ExactLength > 0 {
+		params = append(params, "xl="+strconv.FormatInt(m.ExactLength, 10))
+	}
+	for _, tr := range m.Trackers {
+		params = append(params, "tr="+url.QueryEscape(tr))
+	}
+	for _, ws := range m.WebSeeds {
+		params = append(params, "ws="+url.QueryEscape(ws))
+	}
+	if len(m.SelectOnly) > 0 {
+		params = append(params, "so="+formatSelectOnly(m.SelectOnly))
+	}
+	if len(m.Params) > 0 {
+		params = append(params, m.Params.Encode())
+	}
+
+	return scheme + "?" + strings.Join(params, "&")
+}
+
+// FromMetaInfo builds a magnet for a parsed .torrent file.
+func FromMetaInfo(mi *metainfo.MetaInfo) *Magnet {
+	m := &Magnet{
+		InfoHashV1:  mi.InfoHashV1(),
+		InfoHashV2:  mi.InfoHashV2(),
+		DisplayName: mi.Name(),
+		Trackers:    mi.Trackers(),
+		ExactLength: mi.TotalSize(),
+	}
+
+	if len(mi.URLList) > 0 {
+		m.WebSeeds = append([]string(nil), mi.URLList...)
+	}
+
+	return m
+}
+
+// Normalize parses uri and builds it again, which lowercases and hex encodes the
+// infohashes.
+func Normalize(uri string) (string, error) {
+	m, err := Parse(uri)
+	if err != nil {
+		return "", err
+	}
+
+	return m.String(), nil
+}
+
+// LowercaseInfoHashes rewrites the v1 infohashes of uri to lowercase hex in place,
+// decoding base32 ones, and leaves everything else as it is, unlike Normalize,
+// which rebuilds the whole uri. Malformed infohashes are left alone; use Parse to
+// validate uri first.
+func LowercaseInfoHashes(uri string) string {
+	q := strings.IndexByte(uri, '?')
+	if q < 0 {
+		return uri
+	}
+
+	var b strings.Builder
+	b.WriteString(uri[:q+1])
+
+	params := uri[q+1:]
+	for params != "" {
+		param, sep := params, ""
+		if i := strings.IndexAny(params, "&;"); i >= 0 {
+			param, sep = params[:i], params[i:i+1]
+		}
+		params = params[len(param)+len(sep):]
+
+		key, value, ok := strings.Cut(param, "=")
+		if ok && (key == "xt" || strings.HasPrefix(key, "xt.")) && hasPrefixFold(value, btihPrefix) {
+			if hash, err := normalizeBTIH(value[len(btihPrefix):]); err == nil {
+				param = key + "=" + value[:len(btihPrefix)] + hash
+			}
+		}
+
+		b.WriteString(param)
+		b.WriteString(sep)
+	}
+
+	return b.String()
+}
+
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+func isHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -0,0 +1,148 @@
+package magnet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+const (
+	sampleInfoHash = "ead9241e611e9712f28b20b151f1a3ecd4a6178a"
+	sampleV2Hash   = "caf1e1c30e81cb361b9ee167c4aa64228a7fa4fa9f6105232b28ad099f3a302e"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    *Magnet
+		wantErr bool
+	}{
+		{
+			name: "hex_btih",
+			uri:  "magnet:?xt=urn:btih:EAD9241E611E9712F28B20B151F1A3ECD4A6178A&dn=untitled&tr=http%3A%2F%2Ftracker%2Fannounce&tr=udp%3A%2F%2Fother%3A80",
+			want: &Magnet{
+				InfoHashV1:  sampleInfoHash,
+				DisplayName: "untitled",
+				Trackers:    []string{"http://tracker/announce", "udp://other:80"},
+			},
+		},
+		{
+			name: "base32_btih",
+			uri:  "magnet:?xt=urn:btih:5LMSIHTBD2LRF4ULECYVD4ND5TKKMF4K",
+			want: &Magnet{InfoHashV1: sampleInfoHash},
+		},
+		{
+			name: "hybrid",
+			uri:  "magnet:?xt=urn:btih:" + sampleInfoHash + "&xt=urn:btmh:1220" + sampleV2Hash + "&xl=21&ws=http%3A%2F%2Fseed%2F&so=0,2,4-6&x.pe=1.2.3.4:5",
+			want: &Magnet{
+				InfoHashV1:  sampleInfoHash,
+				InfoHashV2:  sampleV2Hash,
+				ExactLength: 21,
+				WebSeeds:    []string{"http://seed/"},
+				SelectOnly:  []int{0, 2, 4, 5, 6},
+				Params:      map[string][]string{"x.pe": {"1.2.3.4:5"}},
+			},
+		},
+		{
+			name: "semicolons",
+			uri:  "magnet:?dn=a;xt=urn:btih:" + sampleInfoHash + ";tr=x",
+			want: &Magnet{InfoHashV1: sampleInfoHash, DisplayName: "a", Trackers: []string{"x"}},
+		},
+		{
+			name: "v2_only",
+			uri:  "magnet:?xt=urn:btmh:1220" + sampleV2Hash,
+			want: &Magnet{InfoHashV2: sampleV2Hash},
+		},
+		{name: "not_a_magnet", uri: "http://example.com/a.torrent", wantErr: true},
+		{name: "missing_hash", uri: "magnet:?dn=foo", wantErr: true},
+		{name: "short_btih", uri: "magnet:?xt=urn:btih:abc", wantErr: true},
+		{name: "bad_hex", uri: "magnet:?xt=urn:btih:zzd9241e611e9712f28b20b151f1a3ecd4a6178a", wantErr: true},
+		{name: "bad_btmh", uri: "magnet:?xt=urn:btmh:1114" + sampleV2Hash, wantErr: true},
+		{name: "bad_xl", uri: "magnet:?xt=urn:btih:" + sampleInfoHash + "&xl=-1", wantErr: true},
+		{name: "bad_so", uri: "magnet:?xt=urn:btih:" + sampleInfoHash + "&so=3-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.uri)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMagnet_String(t *testing.T) {
+	m := &Magnet{
+		InfoHashV1:  sampleInfoHash,
+		InfoHashV2:  sampleV2Hash,
+		DisplayName: "a b",
+		ExactLength: 21,
+		Trackers:    []string{"http://tracker/announce"},
+		SelectOnly:  []int{6, 0, 4, 5, 2},
+	}
+
+	uri := m.String()
+	assert.Equal(t, "magnet:?xt=urn:btih:"+sampleInfoHash+"&xt=urn:btmh:1220"+sampleV2Hash+"&dn=a+b&xl=21&tr=http%3A%2F%2Ftracker%2Fannounce&so=0,2,4-6", uri)
+
+	parsed, err := Parse(uri)
+	assert.NoError(t, err)
+	assert.Equal(t, sampleInfoHash, parsed.InfoHashV1)
+	assert.Equal(t, []int{0, 2, 4, 5, 6}, parsed.SelectOnly)
+}
+
+func TestMagnet_Matches(t *testing.T) {
+	hybrid := &Magnet{InfoHashV1: sampleInfoHash, InfoHashV2: sampleV2Hash}
+	assert.True(t, hybrid.Matches(sampleInfoHash))
+	assert.True(t, hybrid.Matches("EAD9241E611E9712F28B20B151F1A3ECD4A6178A"))
+	assert.True(t, hybrid.Matches(sampleV2Hash))
+	assert.False(t, hybrid.Matches(""))
+
+	v2 := &Magnet{InfoHashV2: sampleV2Hash}
+	assert.Equal(t, sampleV2Hash[:40], v2.Hash())
+	assert.True(t, v2.Matches(sampleV2Hash[:40]))
+	assert.False(t, v2.Matches(sampleInfoHash))
+}
+
+func TestNormalize(t *testing.T) {
+	got, err := Normalize("magnet:?xt=urn:btih:5LMSIHTBD2LRF4ULECYVD4ND5TKKMF4K&dn=untitled")
+	assert.NoError(t, err)
+	assert.Equal(t, "magnet:?xt=urn:btih:"+sampleInfoHash+"&dn=untitled", got)
+}
+
+func TestLowercaseInfoHashes(t *testing.T) {
+	upper := strings.ToUpper(sampleInfoHash)
+
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "magnet:?xt=urn:btih:" + upper + "&dn=a+b", want: "magnet:?xt=urn:btih:" + sampleInfoHash + "&dn=a+b"},
+		{uri: "magnet:?dn=a;xt.1=URN:BTIH:" + upper + ";tr=x&x.pe=1", want: "magnet:?dn=a;xt.1=URN:BTIH:" + sampleInfoHash + ";tr=x&x.pe=1"},
+		{uri: "magnet:?xt=urn:btih:5LMSIHTBD2LRF4ULECYVD4ND5TKKMF4K&tr=x", want: "magnet:?xt=urn:btih:" + sampleInfoHash + "&tr=x"},
+		// malformed hashes are left alone
+		{uri: "magnet:?xt=urn:btih:ABC&&", want: "magnet:?xt=urn:btih:ABC&&"},
+		{uri: "magnet:", want: "magnet:"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, LowercaseInfoHashes(tt.uri))
+	}
+}
+
+func TestFromMetaInfo(t *testing.T) {
+	mi, err := metainfo.ParseTorrent([]byte("d8:announce17:http://a/announce4:infod6:lengthi5e4:name5:a.txt12:piece lengthi16384e6:pieces0:ee"))
+	assert.NoError(t, err)
+
+	m := FromMetaInfo(mi)
+	assert.Equal(t, mi.InfoHashV1(), m.InfoHashV1)
+	assert.Equal(t, "a.txt", m.DisplayName)
+	assert.Equal(t, int64(5), m.ExactLength)
+	assert.Equal(t, []string{"http://a/announce"}, m.Trackers)
+}
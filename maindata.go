@@ -2,34 +2,139 @@ package qbittorrent
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
 
 	"golang.org/x/exp/slices"
+
+	"github.com/autobrr/go-qbittorrent/errors"
 )
 
+// mainDataDelta is a sync/maindata response before it is merged. Unless FullUpdate is
+// set, torrents, categories and the server state only carry the fields that changed
+// since the requested rid, so they are kept raw and patched onto the previous values.
+type mainDataDelta struct {
+	Rid               int64                      `json:"rid"`
+	FullUpdate        bool                       `json:"full_update"`
+	Torrents          map[string]json.RawMessage `json:"torrents"`
+	TorrentsRemoved   []string                   `json:"torrents_removed"`
+	Categories        map[string]json.RawMessage `json:"categories"`
+	CategoriesRemoved []string                   `json:"categories_removed"`
+	Tags              []string                   `json:"tags"`
+	TagsRemoved       []string                   `json:"tags_removed"`
+	Trackers          map[string][]string        `json:"trackers"`
+	TrackersRemoved   []string                   `json:"trackers_removed"`
+	ServerState       json.RawMessage            `json:"server_state"`
+}
+
+func (c *Client) syncMainDataDeltaCtx(ctx context.Context, rid int64) (*mainDataDelta, error) {
+	opts := map[string]string{
+		"rid": strconv.FormatInt(rid, 10),
+	}
+
+	resp, err := c.getCtx(ctx, "/sync/maindata", opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get main data")
+	}
+
+	defer resp.Body.Close()
+
+	var delta mainDataDelta
+	if err := json.NewDecoder(resp.Body).Decode(&delta); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal body")
+	}
+
+	return &delta, nil
+}
+
+// Update requests the changes since dest.Rid and merges them into dest. Torrents,
+// categories and the server state are patched field by field, so fields missing from an
+// incremental response keep their previous value.
 func (dest *MainData) Update(ctx context.Context, c *Client) error {
-	source, err := c.SyncMainDataCtx(ctx, int64(dest.Rid))
+	delta, err := c.syncMainDataDeltaCtx(ctx, dest.Rid)
 	if err != nil {
 		return err
 	}
 
-	if source.FullUpdate {
-		*dest = *source
-		return nil
+	return dest.apply(delta)
+}
+
+// apply merges a sync/maindata response into dest.
+func (dest *MainData) apply(delta *mainDataDelta) error {
+	if delta.FullUpdate {
+		*dest = MainData{}
+	}
+
+	dest.Rid = delta.Rid
+	dest.FullUpdate = delta.FullUpdate
+
+	if len(delta.ServerState) > 0 {
+		if err := json.Unmarshal(delta.ServerState, &dest.ServerState); err != nil {
+			return errors.Wrap(err, "could not patch server state")
+		}
+	}
+
+	if err := patch(delta.Torrents, &dest.Torrents, func(t *Torrent) {
+		// unmarshal reuses the backing array of slices, which may be shared with a copy
+		t.Trackers = slices.Clone(t.Trackers)
+	}, func(hash string, t *Torrent) {
+		// maindata torrents are keyed by hash and do not carry it themselves
+		t.Hash = hash
+	}); err != nil {
+		return errors.Wrap(err, "could not patch torrents")
+	}
+
+	if err := patch(delta.Categories, &dest.Categories, nil, func(name string, cat *Category) {
+		if cat.Name == "" {
+			cat.Name = name
+		}
+	}); err != nil {
+		return errors.Wrap(err, "could not patch categories")
+	}
+
+	merge(delta.Trackers, &dest.Trackers)
+	remove(delta.TrackersRemoved, &dest.Trackers)
+	remove(delta.CategoriesRemoved, &dest.Categories)
+	remove(delta.TorrentsRemoved, &dest.Torrents)
+	mergeSlice(delta.Tags, &dest.Tags)
+	removeSlice(delta.TagsRemoved, &dest.Tags)
+
+	return nil
+}
+
+// patch unmarshals each raw value onto the existing value with the same key, which only
+// overwrites the fields present in the raw JSON. prepare runs before and fix after the
+// value is unmarshalled, both may be nil.
+func patch[V any](s map[string]json.RawMessage, d *map[string]V, prepare func(*V), fix func(string, *V)) error {
+	if len(s) > 0 && *d == nil {
+		*d = make(map[string]V, len(s))
+	}
+
+	for k, raw := range s {
+		v := (*d)[k]
+		if prepare != nil {
+			prepare(&v)
+		}
+
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return errors.Wrap(err, "could not unmarshal %v", k)
+		}
+
+		if fix != nil {
+			fix(k, &v)
+		}
+
+		(*d)[k] = v
 	}
 
-	dest.Rid = source.Rid
-	dest.ServerState = source.ServerState
-	merge(source.Categories, &dest.Categories)
-	merge(source.Torrents, &dest.Torrents)
-	merge(source.Trackers, &dest.Trackers)
-	remove(source.CategoriesRemoved, &dest.Categories)
-	remove(source.TorrentsRemoved, &dest.Torrents)
-	mergeSlice(source.Tags, &dest.Tags)
-	removeSlice(source.TagsRemoved, &dest.Tags)
 	return nil
 }
 
 func merge[T map[string]V, V any](s T, d *T) {
+	if len(s) > 0 && *d == nil {
+		*d = make(T, len(s))
+	}
+
 	for k, v := range s {
 		(*d)[k] = v
 	}
@@ -0,0 +1,130 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeDelta(t *testing.T, s string) *mainDataDelta {
+	t.Helper()
+
+	var delta mainDataDelta
+	if err := json.Unmarshal([]byte(s), &delta); err != nil {
+		t.Fatal(err)
+	}
+
+	return &delta
+}
+
+func TestMainData_Update(t *testing.T) {
+	// responses keyed by the rid they answer, as sent by qBittorrent
+	responses := map[string]string{
+		"0": `{"rid":1,"full_update":true,
+			"torrents":{
+				"a":{"name":"ubuntu","state":"downloading","progress":0.5,"size":100,"category":"linux","tags":"iso","save_path":"/data"},
+				"b":{"name":"debian","state":"uploading","progress":1,"size":200}
+			},
+			"categories":{"linux":{"name":"linux","savePath":"/data/linux"}},
+			"tags":["iso"],
+			"trackers":{"http://t1/announce":["a"],"http://t2/announce":["b"]},
+			"server_state":{"dl_info_speed":100,"up_info_speed":10,"connection_status":"connected","free_space_on_disk":1000}}`,
+		"1": `{"rid":2,
+			"torrents":{"a":{"progress":0.7}},
+			"server_state":{"dl_info_speed":200}}`,
+		"2": `{"rid":3,
+			"torrents":{"a":{"state":"uploading","progress":1},"c":{"name":"arch","state":"metaDL"}},
+			"categories":{"linux":{"savePath":"/data/distros"}},
+			"tags":["new"],
+			"tags_removed":["iso"],
+			"trackers":{"http://t3/announce":["c"]},
+			"trackers_removed":["http://t2/announce"]}`,
+		"3": `{"rid":4,
+			"torrents_removed":["b"],
+			"categories_removed":["linux"]}`,
+		// the server lost our rid and starts over
+		"4": `{"rid":1,"full_update":true,
+			"torrents":{"c":{"name":"arch","state":"downloading","progress":0.1}}}`,
+	}
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			resp, ok := responses[r.URL.Query().Get("rid")]
+			if !ok {
+				t.Errorf("unexpected rid: %v", r.URL.Query().Get("rid"))
+				return
+			}
+			_, _ = w.Write([]byte(resp))
+		},
+	})
+
+	ctx := context.Background()
+	data := MainData{}
+
+	// rid 0: full update
+	assert.NoError(t, data.Update(ctx, client))
+	assert.Equal(t, int64(1), data.Rid)
+	assert.Len(t, data.Torrents, 2)
+	assert.Equal(t, "a", data.Torrents["a"].Hash)
+	assert.Equal(t, "ubuntu", data.Torrents["a"].Name)
+
+	// rid 1: only progress and one server state field changed
+	assert.NoError(t, data.Update(ctx, client))
+	a := data.Torrents["a"]
+	assert.Equal(t, 0.7, a.Progress)
+	assert.Equal(t, "ubuntu", a.Name)
+	assert.Equal(t, TorrentStateDownloading, a.State)
+	assert.Equal(t, int64(100), a.Size)
+	assert.Equal(t, "linux", a.Category)
+	assert.Equal(t, "iso", a.Tags)
+	assert.Equal(t, "/data", a.SavePath)
+	assert.Equal(t, "debian", data.Torrents["b"].Name)
+	assert.Equal(t, int64(200), data.ServerState.DlInfoSpeed)
+	assert.Equal(t, int64(10), data.ServerState.UpInfoSpeed)
+	assert.Equal(t, "connected", data.ServerState.ConnectionStatus)
+	assert.Equal(t, int64(1000), data.ServerState.FreeSpaceOnDisk)
+
+	// rid 2: state changes, a new torrent, a partial category, tags and trackers
+	assert.NoError(t, data.Update(ctx, client))
+	a = data.Torrents["a"]
+	assert.Equal(t, TorrentStateUploading, a.State)
+	assert.Equal(t, float64(1), a.Progress)
+	assert.Equal(t, "ubuntu", a.Name)
+	assert.Equal(t, "c", data.Torrents["c"].Hash)
+	assert.Equal(t, "arch", data.Torrents["c"].Name)
+	assert.Equal(t, Category{Name: "linux", SavePath: "/data/distros"}, data.Categories["linux"])
+	assert.Equal(t, []string{"new"}, data.Tags)
+	assert.Equal(t, map[string][]string{"http://t1/announce": {"a"}, "http://t3/announce": {"c"}}, data.Trackers)
+	assert.Equal(t, int64(200), data.ServerState.DlInfoSpeed)
+
+	// rid 3: removals
+	assert.NoError(t, data.Update(ctx, client))
+	assert.NotContains(t, data.Torrents, "b")
+	assert.Contains(t, data.Torrents, "a")
+	assert.Empty(t, data.Categories)
+
+	// rid 4: full update replaces everything
+	assert.NoError(t, data.Update(ctx, client))
+	assert.Equal(t, int64(1), data.Rid)
+	assert.True(t, data.FullUpdate)
+	assert.Len(t, data.Torrents, 1)
+	assert.Equal(t, TorrentStateDownloading, data.Torrents["c"].State)
+	assert.Empty(t, data.Tags)
+	assert.Empty(t, data.Trackers)
+	assert.Equal(t, int64(0), data.ServerState.DlInfoSpeed)
+}
+
+func TestMainData_apply_DoesNotShareSlices(t *testing.T) {
+	data := MainData{}
+	assert.NoError(t, data.apply(decodeDelta(t, `{"rid":1,"full_update":true,"torrents":{"a":{"trackers":[{"url":"http://t1"}]}}}`)))
+
+	// a copy taken before the next update must not change
+	before := data.Torrents["a"]
+
+	assert.NoError(t, data.apply(decodeDelta(t, `{"rid":2,"torrents":{"a":{"trackers":[{"url":"http://t2"}]}}}`)))
+	assert.Equal(t, "http://t1", before.Trackers[0].Url)
+	assert.Equal(t, "http://t2", data.Torrents["a"].Trackers[0].Url)
+}
@@ -0,0 +1,291 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/magnet"
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+var (
+	// MetadataMinVersion is the first WebAPI version with torrents/fetchMetadata and
+	// torrents/parseMetadata.
+	MetadataMinVersion = semver.MustParse("2.11.3")
+
+	// stopConditionMinVersion is the first WebAPI version with the stopCondition add
+	// option, which the fallback of FetchMetadataCtx relies on.
+	stopConditionMinVersion = semver.MustParse("2.9.2")
+
+	// MetadataPollInterval is how often pending metadata is polled for.
+	MetadataPollInterval = 1 * time.Second
+
+	ErrMetadataNeedsMagnet = errors.New("fetching metadata on this qBittorrent version requires a magnet uri")
+)
+
+// FetchMetadata fetch the metadata of a magnet or torrent url without adding it.
+func (c *Client) FetchMetadata(source string) (*TorrentMetadata, error) {
+	return c.FetchMetadataCtx(context.Background(), source)
+}
+
+// FetchMetadataCtx fetch the metadata of a magnet or torrent url without adding it.
+//
+// Magnets can take a long time to resolve, or never resolve at all, so ctx should carry
+// a deadline. On servers older than MetadataMinVersion the magnet is added with a
+// MetadataReceived stop condition, so it stops before downloading any data, and is
+// removed once its files are known. Servers older than WebAPI 2.9.2 ignore the stop
+// condition, there ErrUnsupportedVersion is returned.
+func (c *Client) FetchMetadataCtx(ctx context.Context, source string) (*TorrentMetadata, error) {
+	if source == "" {
+		return nil, errors.New("no metadata source provided")
+	}
+
+	if ok, err := c.RequiresMinVersion(MetadataMinVersion); !ok {
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			return nil, err
+		}
+
+		return c.fetchMetadataByAddingCtx(ctx, source)
+	}
+
+	opts := map[string]string{
+		"source": source,
+	}
+
+	for {
+		resp, err := c.postCtx(ctx, "torrents/fetchMetadata", opts)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not fetch metadata: %v", source)
+		}
+
+		meta, err := decodeMetadata(resp)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not fetch metadata: %v", source)
+		}
+
+		if meta != nil {
+			return meta, nil
+		}
+
+		// still downloading metadata from peers
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "metadata not received: %v", source)
+		case <-time.After(MetadataPollInterval):
+		}
+	}
+}
+
+// ParseMetadata parse a .torrent file into metadata without adding it.
+func (c *Client) ParseMetadata(buf []byte) (*TorrentMetadata, error) {
+	return c.ParseMetadataCtx(context.Background(), buf)
+}
+
+// ParseMetadataCtx parse a .torrent file into metadata without adding it.
+// On servers older than MetadataMinVersion the file is parsed locally.
+func (c *Client) ParseMetadataCtx(ctx context.Context, buf []byte) (*TorrentMetadata, error) {
+	if ok, err := c.RequiresMinVersion(MetadataMinVersion); !ok {
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			return nil, err
+		}
+
+		mi, err := metainfo.ParseTorrent(buf)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse torrent")
+		}
+
+		return metadataFromMetaInfo(mi), nil
+	}
+
+	resp, err := c.postMemoryCtx(ctx, "torrents/parseMetadata", buf, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse metadata")
+	}
+
+	meta, err := decodeMetadata(resp)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse metadata")
+	}
+
+	if meta == nil {
+		return nil, errors.New("could not parse metadata: empty response")
+	}
+
+	return meta, nil
+}
+
+// decodeMetadata reads a metadata response. It returns nil metadata when qBittorrent is
+// still fetching it.
+func decodeMetadata(resp *http.Response) (*TorrentMetadata, error) {
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusAccepted:
+		return nil, nil
+	case http.StatusBadRequest:
+		return nil, errors.New("invalid metadata source")
+	case http.StatusNotFound:
+		return nil, errors.New("metadata source not found")
+	default:
+		return nil, errors.New("unexpected status: %v", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read body")
+	}
+
+	var meta TorrentMetadata
+	if err := json.Unmarshal(body, &meta); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal body")
+	}
+
+	// the torrent is known but its info dictionary has not arrived yet
+	if meta.Info.Name == "" && len(meta.Info.Files) == 0 {
+		return nil, nil
+	}
+
+	return &meta, nil
+}
+
+// fetchMetadataByAddingCtx resolves a magnet on servers without torrents/fetchMetadata by
+// adding it until metadata is received, reading its files and removing it again.
+func (c *Client) fetchMetadataByAddingCtx(ctx context.Context, source string) (*TorrentMetadata, error) {
+	if !magnet.IsMagnet(source) {
+		return nil, ErrMetadataNeedsMagnet
+	}
+
+	// without the stop condition the torrent would download its data
+	if ok, err := c.RequiresMinVersion(stopConditionMinVersion); !ok {
+		return nil, err
+	}
+
+	m, err := magnet.Parse(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse magnet")
+	}
+
+	hash := m.Hash()
+
+	// never touch a torrent the user already has, read it instead
+	existing, err := c.GetTorrentsCtx(ctx, TorrentFilterOptions{Hashes: []string{hash}})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not check for existing torrent: %v", hash)
+	}
+
+	if len(existing) == 0 {
+		// a stopped torrent never fetches metadata, let the stop condition stop it instead
+		opts := TorrentAddOptions{
+			StopCondition: TorrentStopConditionMetadataReceived,
+		}
+
+		if err := c.AddTorrentFromUrlCtx(ctx, source, opts.Prepare()); err != nil {
+			return nil, errors.Wrap(err, "could not add magnet for metadata")
+		}
+
+		defer func() {
+			// the caller's context may be done by now, cleanup regardless
+			if err := c.DeleteTorrentsCtx(context.Background(), []string{hash}, false); err != nil {
+				c.log.Warn("could not remove metadata torrent", "hash", hash, "error", err)
+			}
+		}()
+	}
+
+	for {
+		torrents, err := c.GetTorrentsCtx(ctx, TorrentFilterOptions{Hashes: []string{hash}})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get torrent: %v", hash)
+		}
+
+		if len(torrents) > 0 && torrents[0].State != TorrentStateMetaDl {
+			t := torrents[0]
+
+			files, err := c.GetFilesInformationCtx(ctx, hash)
+			if err != nil {
+				return nil, errors.Wrap(err, "could not get files: %v", hash)
+			}
+
+			if files != nil && len(*files) > 0 {
+				return metadataFromTorrent(t, *files), nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, errors.Wrap(ctx.Err(), "metadata not received: %v", hash)
+		case <-time.After(MetadataPollInterval):
+		}
+	}
+}
+
+func metadataFromTorrent(t Torrent, files TorrentFiles) *TorrentMetadata {
+	meta := &TorrentMetadata{
+		InfohashV1: t.InfohashV1,
+		InfohashV2: t.InfohashV2,
+		Info: TorrentMetadataInfo{
+			Name: t.Name,
+		},
+	}
+
+	for _, f := range files {
+		meta.Info.Files = append(meta.Info.Files, TorrentMetadataFile{
+			Index: f.Index,
+			Name:  f.Name,
+			Size:  f.Size,
+		})
+	}
+
+	if t.Tracker != "" {
+		meta.Trackers = []TorrentMetadataTracker{{Url: t.Tracker}}
+	}
+
+	return meta
+}
+
+func metadataFromMetaInfo(mi *metainfo.MetaInfo) *TorrentMetadata {
+	meta := &TorrentMetadata{
+		InfohashV1: mi.InfoHashV1(),
+		InfohashV2: mi.InfoHashV2(),
+		Comment:    mi.Comment,
+		CreatedBy:  mi.CreatedBy,
+		WebSeeds:   mi.URLList,
+		Info: TorrentMetadataInfo{
+			Name:        mi.Info.Name,
+			PieceLength: mi.Info.PieceLength,
+			PiecesNum:   int64(len(mi.Info.Pieces) / 20),
+			Private:     mi.IsPrivate(),
+		},
+	}
+
+	if !mi.CreationDate.IsZero() {
+		meta.CreationDate = mi.CreationDate.Unix()
+	}
+
+	for i, f := range mi.Files() {
+		meta.Info.Files = append(meta.Info.Files, TorrentMetadataFile{
+			Index: i,
+			Name:  path.Join(f.Path...),
+			Size:  f.Length,
+		})
+	}
+
+	for tier, urls := range mi.AnnounceList {
+		for _, u := range urls {
+			meta.Trackers = append(meta.Trackers, TorrentMetadataTracker{Url: u, Tier: tier})
+		}
+	}
+
+	if len(meta.Trackers) == 0 && mi.Announce != "" {
+		meta.Trackers = []TorrentMetadataTracker{{Url: mi.Announce}}
+	}
+
+	return meta
+}
@@ -0,0 +1,102 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autobrr/go-qbittorrent/magnet"
+)
+
+const (
+	// a sample torrent that only contains one folder "untitled" and one file "untitled.txt".
+	sampleTorrent  = "d10:created by18:qBittorrent v5.1.013:creation datei1747004328e4:infod5:filesld6:lengthi21e4:pathl12:untitled.txteee4:name8:untitled12:piece lengthi16384e6:pieces20:\xb5|\x901\xce\xa3\xdb @$\xce\xbd\xd3\xb0\x0e\xd3\xba\xc0\xcc\xbd7:privatei1eee"
+	sampleInfoHash = "ead9241e611e9712f28b20b151f1a3ecd4a6178a"
+)
+
+func TestClient_FetchMetadataCtx(t *testing.T) {
+	var polls atomic.Int32
+
+	client := newTestClient(t, "2.12.0", map[string]http.HandlerFunc{
+		"torrents/fetchMetadata": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "magnet:?xt=urn:btih:"+sampleInfoHash, r.FormValue("source"))
+
+			// pretend the first request is still waiting for peers
+			if polls.Add(1) == 1 {
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+
+			_, _ = w.Write([]byte(`{"infohash_v1":"` + sampleInfoHash + `","info":{"name":"untitled","length":21,"files":[{"index":0,"name":"untitled/untitled.txt","size":21}]}}`))
+		},
+	})
+
+	setForTest(t, &MetadataPollInterval, 10*time.Millisecond)
+
+	meta, err := client.FetchMetadataCtx(context.Background(), "magnet:?xt=urn:btih:"+sampleInfoHash)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), polls.Load())
+	assert.Equal(t, sampleInfoHash, meta.InfohashV1)
+	assert.Equal(t, int64(21), meta.TotalSize())
+	assert.Equal(t, []TorrentMetadataFile{{Index: 0, Name: "untitled/untitled.txt", Size: 21}}, meta.Info.Files)
+}
+
+func TestClient_ParseMetadataCtx_LocalFallback(t *testing.T) {
+	client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+		"torrents/parseMetadata": func(w http.ResponseWriter, r *http.Request) {
+			t.Error("parseMetadata must not be called on old servers")
+		},
+	})
+
+	meta, err := client.ParseMetadataCtx(context.Background(), []byte(sampleTorrent))
+	assert.NoError(t, err)
+	assert.Equal(t, sampleInfoHash, meta.InfohashV1)
+	assert.Equal(t, "untitled", meta.Info.Name)
+	assert.True(t, meta.Info.Private)
+	assert.Equal(t, int64(1), meta.Info.PiecesNum)
+	assert.Equal(t, int64(21), meta.TotalSize())
+	assert.Equal(t, []TorrentMetadataFile{{Index: 0, Name: "untitled/untitled.txt", Size: 21}}, meta.Info.Files)
+}
+
+func TestClient_FetchMetadataCtx_FallbackNeedsMagnet(t *testing.T) {
+	client := newTestClient(t, "2.9.3", nil)
+
+	_, err := client.FetchMetadataCtx(context.Background(), "http://example.com/a.torrent")
+	assert.ErrorIs(t, err, ErrMetadataNeedsMagnet)
+}
+
+func TestClient_FetchMetadataCtx_FallbackUnsupportedVersion(t *testing.T) {
+	var added bool
+	client := newTestClient(t, "2.9.1", map[string]http.HandlerFunc{
+		"torrents/add": func(w http.ResponseWriter, r *http.Request) {
+			added = true
+		},
+	})
+
+	_, err := client.FetchMetadataCtx(context.Background(), "magnet:?xt=urn:btih:"+sampleInfoHash)
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+	assert.False(t, added)
+}
+
+func TestClient_AddTorrentFromUrlCtx_Magnet(t *testing.T) {
+	var urls string
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/add": func(w http.ResponseWriter, r *http.Request) {
+			urls = r.FormValue("urls")
+		},
+	})
+
+	// base32 infohashes are rewritten to hex, the rest of the uri is kept
+	err := client.AddTorrentFromUrlCtx(context.Background(), "magnet:?xt=urn:btih:5LMSIHTBD2LRF4ULECYVD4ND5TKKMF4K;dn=a\nhttp://example.com/a.torrent", map[string]string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "magnet:?xt=urn:btih:"+sampleInfoHash+";dn=a\nhttp://example.com/a.torrent", urls)
+
+	urls = ""
+	err = client.AddTorrentFromUrlCtx(context.Background(), "magnet:?xt=urn:btih:abc", map[string]string{})
+	assert.ErrorIs(t, err, magnet.ErrInvalidMagnet)
+	assert.Empty(t, urls)
+}
@@ -0,0 +1,307 @@
+package metainfo
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+var (
+	ErrInvalidBencode = errors.Sentinel("invalid bencode data")
+	ErrTrailingData   = errors.Sentinel("trailing data after bencode value")
+)
+
+// MaxDepth is how deeply lists and dictionaries may nest, so crafted input cannot exhaust
+// the stack.
+const MaxDepth = 100
+
+// RawMessage is a raw encoded bencode value. It is kept as-is when encoding, which makes
+// it possible to hash or re-emit a dictionary byte for byte.
+type RawMessage []byte
+
+// Decode decodes a single bencode value.
+//
+// Integers are returned as int64, strings as string, lists as []interface{} and
+// dictionaries as map[string]interface{}.
+func Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, errors.Wrap(ErrTrailingData, "%d bytes left", len(d.data)-d.pos)
+	}
+
+	return v, nil
+}
+
+// DecodeRawDict decodes a bencode dictionary without decoding its values. Each value is
+// returned as the exact bytes it was encoded with.
+func DecodeRawDict(data []byte) (map[string]RawMessage, error) {
+	d := decoder{data: data}
+
+	if d.peek() != 'd' {
+		return nil, errors.Wrap(ErrInvalidBencode, "expected dictionary at offset 0")
+	}
+	d.pos++
+
+	dict := make(map[string]RawMessage)
+	for d.peek() != 'e' {
+		key, err := d.string()
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode dictionary key")
+		}
+
+		start := d.pos
+		if _, err := d.value(); err != nil {
+			return nil, errors.Wrap(err, "could not decode value for key %q", key)
+		}
+
+		dict[key] = RawMessage(d.data[start:d.pos])
+	}
+	d.pos++
+
+	if d.pos != len(d.data) {
+		return nil, errors.Wrap(ErrTrailingData, "%d bytes left", len(d.data)-d.pos)
+	}
+
+	return dict, nil
+}
+
+type decoder struct {
+	data  []byte
+	pos   int
+	depth int
+}
+
+// peek returns the next byte, or 0 when the input is exhausted.
+func (d *decoder) peek() byte {
+	if d.pos >= len(d.data) {
+		return 0
+	}
+
+	return d.data[d.pos]
+}
+
+func (d *decoder) value() (interface{}, error) {
+	switch c := d.peek(); {
+	case c == 'i':
+		return d.int()
+	case c == 'l', c == 'd':
+		if d.depth >= MaxDepth {
+			return nil, errors.Wrap(ErrInvalidBencode, "nesting deeper than %d at offset %d", MaxDepth, d.pos)
+		}
+
+		d.depth++
+		defer func() { d.depth-- }()
+
+		if c == 'l' {
+			return d.list()
+		}
+		return d.dict()
+	case c >= '0' && c <= '9':
+		return d.string()
+	case c == 0:
+		return nil, errors.Wrap(ErrInvalidBencode, "unexpected end of data")
+	default:
+		return nil, errors.Wrap(ErrInvalidBencode, "unexpected %q at offset %d", c, d.pos)
+	}
+}
+
+func (d *decoder) int() (int64, error) {
+	d.pos++ // 'i'
+
+	end := bytes.IndexByte(d.data[d.pos:], 'e')
+	if end < 0 {
+		return 0, errors.Wrap(ErrInvalidBencode, "unterminated integer at offset %d", d.pos)
+	}
+
+	s := string(d.data[d.pos : d.pos+end])
+	if s == "-0" || (len(s) > 1 && s[0] == '0') || (len(s) > 2 && s[:2] == "-0") {
+		return 0, errors.Wrap(ErrInvalidBencode, "non-canonical integer %q at offset %d", s, d.pos)
+	}
+
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(ErrInvalidBencode, "invalid integer %q at offset %d", s, d.pos)
+	}
+
+	d.pos += end + 1
+
+	return n, nil
+}
+
+func (d *decoder) string() (string, error) {
+	colon := bytes.IndexByte(d.data[d.pos:], ':')
+	if colon < 0 {
+		return "", errors.Wrap(ErrInvalidBencode, "missing string length separator at offset %d", d.pos)
+	}
+
+	n, err := strconv.Atoi(string(d.data[d.pos : d.pos+colon]))
+	if err != nil || n < 0 {
+		return "", errors.Wrap(ErrInvalidBencode, "invalid string length at offset %d", d.pos)
+	}
+
+	start := d.pos + colon + 1
+	if n > len(d.data)-start {
+		return "", errors.Wrap(ErrInvalidBencode, "string at offset %d exceeds input", d.pos)
+	}
+
+	d.pos = start + n
+
+	return string(d.data[start:d.pos]), nil
+}
+
+func (d *decoder) list() ([]interface{}, error) {
+	d.pos++ // 'l'
+
+	list := make([]interface{}, 0)
+	for d.peek() != 'e' {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, v)
+	}
+	d.pos++
+
+	return list, nil
+}
+
+func (d *decoder) dict() (map[string]interface{}, error) {
+	d.pos++ // 'd'
+
+	dict := make(map[string]interface{})
+	for d.peek() != 'e' {
+		if c := d.peek(); c < '0' || c > '9' {
+			if c == 0 {
+				return nil, errors.Wrap(ErrInvalidBencode, "unexpected end of data")
+			}
+			return nil, errors.Wrap(ErrInvalidBencode, "dictionary key is not a string at offset %d", d.pos)
+		}
+
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+
+		dict[key] = v
+	}
+	d.pos++
+
+	return dict, nil
+}
+
+// Encode encodes v as bencode. Dictionary keys are written in sorted order, as the
+// specification requires.
+//
+// Supported types are integers, string, []byte, RawMessage, []string, []interface{},
+// map[string]interface{} and map[string]RawMessage.
+func Encode(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func encode(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case RawMessage:
+		buf.Write(v)
+	case string:
+		encodeString(buf, v)
+	case []byte:
+		encodeString(buf, string(v))
+	case int:
+		encodeInt(buf, int64(v))
+	case int32:
+		encodeInt(buf, int64(v))
+	case int64:
+		encodeInt(buf, v)
+	case uint32:
+		encodeInt(buf, int64(v))
+	case bool:
+		if v {
+			encodeInt(buf, 1)
+		} else {
+			encodeInt(buf, 0)
+		}
+	case []string:
+		buf.WriteByte('l')
+		for _, s := range v {
+			encodeString(buf, s)
+		}
+		buf.WriteByte('e')
+	case [][]string:
+		buf.WriteByte('l')
+		for _, l := range v {
+			if err := encode(buf, l); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case []interface{}:
+		buf.WriteByte('l')
+		for _, item := range v {
+			if err := encode(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]interface{}:
+		buf.WriteByte('d')
+		for _, k := range sortedKeys(v) {
+			encodeString(buf, k)
+			if err := encode(buf, v[k]); err != nil {
+				return errors.Wrap(err, "could not encode key %q", k)
+			}
+		}
+		buf.WriteByte('e')
+	case map[string]RawMessage:
+		buf.WriteByte('d')
+		for _, k := range sortedKeys(v) {
+			encodeString(buf, k)
+			buf.Write(v[k])
+		}
+		buf.WriteByte('e')
+	default:
+		return errors.New("unsupported bencode type %T", v)
+	}
+
+	return nil
+}
+
+func encodeString(buf *bytes.Buffer, s string) {
+	buf.WriteString(strconv.Itoa(len(s)))
+	buf.WriteByte(':')
+	buf.WriteString(s)
+}
+
+func encodeInt(buf *bytes.Buffer, n int64) {
+	buf.WriteByte('i')
+	buf.WriteString(strconv.FormatInt(n, 10))
+	buf.WriteByte('e')
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,470 @@
+// Package metainfo parses and encodes .torrent files (BEP 3, BEP 52) without talking to
+// qBittorrent, so torrents can be inspected before they are added.
+package metainfo
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+var (
+	ErrMissingInfo = errors.Sentinel("torrent has no info dictionary")
+)
+
+// Version is the BitTorrent protocol version of a torrent.
+type Version int
+
+const (
+	VersionUnknown Version = iota
+	VersionV1
+	VersionV2
+	VersionHybrid
+)
+
+func (v Version) String() string {
+	switch v {
+	case VersionV1:
+		return "v1"
+	case VersionV2:
+		return "v2"
+	case VersionHybrid:
+		return "hybrid"
+	default:
+		return "unknown"
+	}
+}
+
+// MetaInfo is a parsed .torrent file.
+type MetaInfo struct {
+	Announce     string
+	AnnounceList [][]string
+	Comment      string
+	CreatedBy    string
+	CreationDate time.Time
+	Encoding     string
+	URLList      []string
+	Info         Info
+
+	// InfoBytes is the info dictionary exactly as it was encoded, used to compute the
+	// infohashes.
+	InfoBytes RawMessage
+}
+
+// Info is the info dictionary of a torrent.
+type Info struct {
+	Name        string
+	PieceLength int64
+	Pieces      []byte
+	Private     bool
+	Source      string
+	MetaVersion int64
+
+	// Length is set for single file v1 torrents.
+	Length int64
+
+	// Files is the v1 file list, empty for single file torrents.
+	Files []FileInfo
+
+	// FileTree is the v2 file list, flattened. Paths are relative to Name.
+	FileTree []FileInfo
+}
+
+// FileInfo is a single file of a torrent.
+type FileInfo struct {
+	Length     int64
+	Path       []string
+	PiecesRoot []byte // v2 only
+	Attr       string // BEP 47 attributes, "p" marks padding files
+}
+
+// IsPadding reports whether the file is a BEP 47 padding file.
+func (f FileInfo) IsPadding() bool {
+	return strings.Contains(f.Attr, "p")
+}
+
+// ParseTorrent parses a .torrent file, as passed to the Add* methods or returned by
+// ExportTorrentCtx.
+func ParseTorrent(buf []byte) (*MetaInfo, error) {
+	root, err := DecodeRawDict(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode torrent")
+	}
+
+	rawInfo, ok := root["info"]
+	if !ok {
+		return nil, ErrMissingInfo
+	}
+
+	m := &MetaInfo{InfoBytes: rawInfo}
+
+	for key, raw := range root {
+		if key == "info" {
+			continue
+		}
+
+		v, err := Decode(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not decode %q", key)
+		}
+
+		switch key {
+		case "announce":
+			m.Announce, _ = v.(string)
+		case "announce-list":
+			m.AnnounceList = toStringLists(v)
+		case "comment":
+			m.Comment, _ = v.(string)
+		case "created by":
+			m.CreatedBy, _ = v.(string)
+		case "creation date":
+			if n, ok := v.(int64); ok && n > 0 {
+				m.CreationDate = time.Unix(n, 0)
+			}
+		case "encoding":
+			m.Encoding, _ = v.(string)
+		case "url-list":
+			switch u := v.(type) {
+			case string:
+				m.URLList = []string{u}
+			case []interface{}:
+				m.URLList = toStrings(u)
+			}
+		}
+	}
+
+	v, err := Decode(rawInfo)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode info dictionary")
+	}
+
+	info, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.Wrap(ErrMissingInfo, "info is not a dictionary")
+	}
+
+	if err := m.Info.parse(info); err != nil {
+		return nil, errors.Wrap(err, "could not parse info dictionary")
+	}
+
+	return m, nil
+}
+
+func (i *Info) parse(d map[string]interface{}) error {
+	i.Name, _ = d["name"].(string)
+	i.PieceLength, _ = d["piece length"].(int64)
+	i.Source, _ = d["source"].(string)
+	i.MetaVersion, _ = d["meta version"].(int64)
+
+	if p, ok := d["pieces"].(string); ok {
+		i.Pieces = []byte(p)
+	}
+
+	if p, ok := d["private"].(int64); ok {
+		i.Private = p == 1
+	}
+
+	if l, ok := d["length"].(int64); ok {
+		i.Length = l
+	}
+
+	if files, ok := d["files"].([]interface{}); ok {
+		for n, f := range files {
+			fd, ok := f.(map[string]interface{})
+			if !ok {
+				return errors.New("file %d is not a dictionary", n)
+			}
+
+			file := FileInfo{Path: toStrings(fd["path"])}
+			file.Length, _ = fd["length"].(int64)
+			file.Attr, _ = fd["attr"].(string)
+			i.Files = append(i.Files, file)
+		}
+	}
+
+	if tree, ok := d["file tree"].(map[string]interface{}); ok {
+		walkFileTree(tree, nil, &i.FileTree)
+	}
+
+	if i.Name == "" {
+		return errors.New("missing name")
+	}
+
+	if i.Version() == VersionUnknown {
+		return errors.New("torrent is neither v1 nor v2")
+	}
+
+	return nil
+}
+
+// walkFileTree flattens a BEP 52 file tree. A file is a dictionary holding a single
+// empty key, whose value describes the file.
+func walkFileTree(node map[string]interface{}, prefix []string, out *[]FileInfo) {
+	for _, name := range sortedKeys(node) {
+		child, ok := node[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if name == "" {
+			file := FileInfo{Path: append([]string(nil), prefix...)}
+			file.Length, _ = child["length"].(int64)
+			file.Attr, _ = child["attr"].(string)
+			if root, ok := child["pieces root"].(string); ok {
+				file.PiecesRoot = []byte(root)
+			}
+			*out = append(*out, file)
+			continue
+		}
+
+		walkFileTree(child, append(prefix, name), out)
+	}
+}
+
+// Version reports whether the torrent is v1, v2 or hybrid.
+func (i *Info) Version() Version {
+	// an empty v1 torrent still carries an empty pieces string
+	v1 := i.Pieces != nil
+	v2 := i.MetaVersion == 2 && len(i.FileTree) > 0
+
+	switch {
+	case v1 && v2:
+		return VersionHybrid
+	case v2:
+		return VersionV2
+	case v1:
+		return VersionV1
+	default:
+		return VersionUnknown
+	}
+}
+
+// IsMultiFile reports whether the content is stored in a folder named after the torrent.
+func (i *Info) IsMultiFile() bool {
+	if len(i.Files) > 0 {
+		return true
+	}
+
+	if len(i.FileTree) > 1 {
+		return true
+	}
+
+	return len(i.FileTree) == 1 && len(i.FileTree[0].Path) > 1
+}
+
+// FileList returns the files of the torrent with padding files removed. Paths are
+// relative to the torrent root, and include Name for multi file torrents, matching the
+// names returned by GetFilesInformationCtx.
+func (i *Info) FileList() []FileInfo {
+	var files []FileInfo
+
+	switch {
+	case len(i.Files) > 0:
+		for _, f := range i.Files {
+			if f.IsPadding() {
+				continue
+			}
+			f.Path = append([]string{i.Name}, f.Path...)
+			files = append(files, f)
+		}
+	case len(i.FileTree) > 0:
+		for _, f := range i.FileTree {
+			if f.IsPadding() {
+				continue
+			}
+			// a v2 single file torrent stores the file under its own name
+			if i.IsMultiFile() {
+				f.Path = append([]string{i.Name}, f.Path...)
+			}
+			files = append(files, f)
+		}
+	default:
+		files = append(files, FileInfo{Length: i.Length, Path: []string{i.Name}})
+	}
+
+	return files
+}
+
+// TotalSize returns the size of the content, excluding padding files.
+func (i *Info) TotalSize() int64 {
+	var size int64
+	for _, f := range i.FileList() {
+		size += f.Length
+	}
+
+	return size
+}
+
+// Name returns the torrent name.
+func (m *MetaInfo) Name() string {
+	return m.Info.Name
+}
+
+// Files returns the files of the torrent, see Info.FileList.
+func (m *MetaInfo) Files() []FileInfo {
+	return m.Info.FileList()
+}
+
+// FilePaths returns the slash separated file paths, in the same form qBittorrent uses.
+func (m *MetaInfo) FilePaths() []string {
+	files := m.Files()
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, path.Join(f.Path...))
+	}
+
+	return paths
+}
+
+// TotalSize returns the size of the content.
+func (m *MetaInfo) TotalSize() int64 {
+	return m.Info.TotalSize()
+}
+
+// IsPrivate reports whether the private flag is set.
+func (m *MetaInfo) IsPrivate() bool {
+	return m.Info.Private
+}
+
+// Source returns the source tag of the info dictionary, set by many private trackers.
+func (m *MetaInfo) Source() string {
+	return m.Info.Source
+}
+
+// Version reports whether the torrent is v1, v2 or hybrid.
+func (m *MetaInfo) Version() Version {
+	return m.Info.Version()
+}
+
+// Trackers returns every announce url once, in tier order.
+func (m *MetaInfo) Trackers() []string {
+	var trackers []string
+	seen := make(map[string]struct{})
+
+	add := func(u string) {
+		if _, ok := seen[u]; ok || u == "" {
+			return
+		}
+		seen[u] = struct{}{}
+		trackers = append(trackers, u)
+	}
+
+	for _, tier := range m.AnnounceList {
+		for _, u := range tier {
+			add(u)
+		}
+	}
+	add(m.Announce)
+
+	return trackers
+}
+
+// InfoHashV1 returns the hex encoded SHA-1 infohash, or an empty string for v2 only
+// torrents.
+func (m *MetaInfo) InfoHashV1() string {
+	if v := m.Version(); v != VersionV1 && v != VersionHybrid {
+		return ""
+	}
+
+	sum := sha1.Sum(m.InfoBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// InfoHashV2 returns the hex encoded SHA-256 infohash, or an empty string for v1 only
+// torrents.
+func (m *MetaInfo) InfoHashV2() string {
+	if v := m.Version(); v != VersionV2 && v != VersionHybrid {
+		return ""
+	}
+
+	sum := sha256.Sum256(m.InfoBytes)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash returns the id qBittorrent uses for the torrent: the v1 infohash if there is one,
+// otherwise the v2 infohash truncated to 40 characters.
+func (m *MetaInfo) Hash() string {
+	if h := m.InfoHashV1(); h != "" {
+		return h
+	}
+
+	if h := m.InfoHashV2(); h != "" {
+		return h[:40]
+	}
+
+	return ""
+}
+
+// Bytes encodes the torrent back into a .torrent file. The info dictionary is written
+// unchanged so the infohashes stay the same.
+func (m *MetaInfo) Bytes() ([]byte, error) {
+	if len(m.InfoBytes) == 0 {
+		return nil, ErrMissingInfo
+	}
+
+	d := map[string]interface{}{
+		"info": m.InfoBytes,
+	}
+
+	if m.Announce != "" {
+		d["announce"] = m.Announce
+	}
+	if len(m.AnnounceList) > 0 {
+		d["announce-list"] = m.AnnounceList
+	}
+	if m.Comment != "" {
+		d["comment"] = m.Comment
+	}
+	if m.CreatedBy != "" {
+		d["created by"] = m.CreatedBy
+	}
+	if !m.CreationDate.IsZero() {
+		d["creation date"] = m.CreationDate.Unix()
+	}
+	if m.Encoding != "" {
+		d["encoding"] = m.Encoding
+	}
+	if len(m.URLList) > 0 {
+		d["url-list"] = m.URLList
+	}
+
+	return Encode(d)
+}
+
+func toStrings(v interface{}) []string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+
+	return out
+}
+
+func toStringLists(v interface{}) [][]string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	out := make([][]string, 0, len(list))
+	for _, item := range list {
+		if tier := toStrings(item); len(tier) > 0 {
+			out = append(out, tier)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,181 @@
+package metainfo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	// a sample torrent that only contains one folder "untitled" and one file "untitled.txt".
+	sampleTorrent  = "d10:created by18:qBittorrent v5.1.013:creation datei1747004328e4:infod5:filesld6:lengthi21e4:pathl12:untitled.txteee4:name8:untitled12:piece lengthi16384e6:pieces20:\xb5|\x901\xce\xa3\xdb @$\xce\xbd\xd3\xb0\x0e\xd3\xba\xc0\xcc\xbd7:privatei1eee"
+	sampleInfoHash = "ead9241e611e9712f28b20b151f1a3ecd4a6178a"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "int", data: "i42e", want: int64(42)},
+		{name: "negative_int", data: "i-3e", want: int64(-3)},
+		{name: "string", data: "4:spam", want: "spam"},
+		{name: "empty_string", data: "0:", want: ""},
+		{name: "list", data: "l4:spami7ee", want: []interface{}{"spam", int64(7)}},
+		{name: "dict", data: "d3:cow3:moo4:spaml1:aee", want: map[string]interface{}{"cow": "moo", "spam": []interface{}{"a"}}},
+		{name: "leading_zero", data: "i03e", wantErr: true},
+		{name: "negative_zero", data: "i-0e", wantErr: true},
+		{name: "short_string", data: "5:spam", wantErr: true},
+		{name: "unterminated_list", data: "l4:spam", wantErr: true},
+		{name: "non_string_key", data: "di1ei2ee", wantErr: true},
+		{name: "trailing_data", data: "i1ei2e", wantErr: true},
+		{name: "empty", data: "", wantErr: true},
+		{name: "max_depth", data: strings.Repeat("l", MaxDepth) + strings.Repeat("e", MaxDepth), want: nested(MaxDepth)},
+		{name: "too_deep", data: strings.Repeat("l", MaxDepth+1) + strings.Repeat("e", MaxDepth+1), wantErr: true},
+		{name: "too_deep_unterminated", data: strings.Repeat("l", 1<<20), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode([]byte(tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// nested returns depth empty lists nested into each other.
+func nested(depth int) interface{} {
+	v := []interface{}{}
+	for i := 1; i < depth; i++ {
+		v = []interface{}{v}
+	}
+	return v
+}
+
+func TestEncode(t *testing.T) {
+	got, err := Encode(map[string]interface{}{
+		"spam": []string{"a", "b"},
+		"cow":  "moo",
+		"n":    42,
+		"raw":  RawMessage("i1e"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "d3:cow3:moo1:ni42e3:rawi1e4:spaml1:a1:bee", string(got))
+
+	_, err = Encode(1.5)
+	assert.Error(t, err)
+}
+
+func TestParseTorrent(t *testing.T) {
+	m, err := ParseTorrent([]byte(sampleTorrent))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "untitled", m.Name())
+	assert.Equal(t, "qBittorrent v5.1.0", m.CreatedBy)
+	assert.Equal(t, int64(1747004328), m.CreationDate.Unix())
+	assert.Equal(t, VersionV1, m.Version())
+	assert.True(t, m.IsPrivate())
+	assert.Equal(t, int64(21), m.TotalSize())
+	assert.Equal(t, []string{"untitled/untitled.txt"}, m.FilePaths())
+	assert.Equal(t, sampleInfoHash, m.InfoHashV1())
+	assert.Equal(t, "", m.InfoHashV2())
+	assert.Equal(t, sampleInfoHash, m.Hash())
+	assert.Empty(t, m.Trackers())
+
+	// re-encoding keeps the info dictionary and therefore the infohash
+	buf, err := m.Bytes()
+	assert.NoError(t, err)
+	assert.Equal(t, sampleTorrent, string(buf))
+}
+
+func TestParseTorrent_Versions(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		version   Version
+		files     []string
+		size      int64
+		trackers  []string
+		source    string
+		hasV1Hash bool
+		hasV2Hash bool
+	}{
+		{
+			name:      "v1_single_file",
+			data:      "d8:announce17:http://a/announce13:announce-listll17:http://a/announceel17:http://b/announceee4:infod6:lengthi5e4:name5:a.txt12:piece lengthi16384e6:pieces0:6:source3:ABCee",
+			version:   VersionV1,
+			files:     []string{"a.txt"},
+			size:      5,
+			trackers:  []string{"http://a/announce", "http://b/announce"},
+			source:    "ABC",
+			hasV1Hash: true,
+		},
+		{
+			name:      "v2_multi_file",
+			data:      "d4:infod9:file treed5:a.txtd0:d6:lengthi2e11:pieces root0:ee3:dird5:b.txtd0:d6:lengthi3e11:pieces root0:eeee12:meta versioni2e4:name3:top12:piece lengthi16384eee",
+			version:   VersionV2,
+			files:     []string{"top/a.txt", "top/dir/b.txt"},
+			size:      5,
+			hasV2Hash: true,
+		},
+		{
+			name:      "v2_single_file",
+			data:      "d4:infod9:file treed5:a.txtd0:d6:lengthi2e11:pieces root0:eee12:meta versioni2e4:name5:a.txt12:piece lengthi16384eee",
+			version:   VersionV2,
+			files:     []string{"a.txt"},
+			size:      2,
+			hasV2Hash: true,
+		},
+		{
+			name:      "hybrid_with_padding",
+			data:      "d4:infod9:file treed5:a.txtd0:d6:lengthi2e11:pieces root0:ee5:b.txtd0:d6:lengthi3e11:pieces root0:eee5:filesld6:lengthi2e4:pathl5:a.txteed4:attr1:p6:lengthi16382e4:pathl4:.pad5:16382eed6:lengthi3e4:pathl5:b.txteee12:meta versioni2e4:name3:top12:piece lengthi16384e6:pieces0:ee",
+			version:   VersionHybrid,
+			files:     []string{"top/a.txt", "top/b.txt"},
+			size:      5,
+			hasV1Hash: true,
+			hasV2Hash: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseTorrent([]byte(tt.data))
+			assert.NoError(t, err)
+
+			assert.Equal(t, tt.version, m.Version())
+			assert.Equal(t, tt.files, m.FilePaths())
+			assert.Equal(t, tt.size, m.TotalSize())
+			assert.Equal(t, tt.trackers, m.Trackers())
+			assert.Equal(t, tt.source, m.Source())
+			assert.Equal(t, tt.hasV1Hash, len(m.InfoHashV1()) == 40)
+			assert.Equal(t, tt.hasV2Hash, len(m.InfoHashV2()) == 64)
+			assert.Len(t, m.Hash(), 40)
+		})
+	}
+}
+
+func TestParseTorrent_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "not_bencode", data: "<html></html>"},
+		{name: "not_a_dict", data: "l4:infoe"},
+		{name: "missing_info", data: "d8:announce1:xe"},
+		{name: "missing_name", data: "d4:infod6:lengthi1e6:pieces0:ee"},
+		{name: "no_files", data: "d4:infod4:name1:aee"},
+		{name: "too_deep", data: "d4:infod5:files" + strings.Repeat("l", 1<<20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTorrent([]byte(tt.data))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
-	"net/http/httputil"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/magnet"
+	"github.com/autobrr/go-qbittorrent/metainfo"
 
 	"github.com/Masterminds/semver"
 )
@@ -38,7 +39,7 @@ func (c *Client) LoginCtx(ctx context.Context) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode == http.StatusForbidden {
-		return errors.New("User's IP is banned for too many failed login attempts")
+		return errors.Wrap(ErrLoginFailed, "User's IP is banned for too many failed login attempts")
 	} else if resp.StatusCode != http.StatusOK { // check for correct status code
 		return errors.New("qbittorrent login bad status %v", resp.StatusCode)
 	}
@@ -51,7 +52,7 @@ func (c *Client) LoginCtx(ctx context.Context) error {
 
 	// read output
 	if bodyString == "Fails." {
-		return errors.New("bad credentials")
+		return errors.Wrap(ErrLoginFailed, "bad credentials")
 	}
 
 	// good response == "Ok."
@@ -60,10 +61,10 @@ func (c *Client) LoginCtx(ctx context.Context) error {
 	if cookies := resp.Cookies(); len(cookies) > 0 {
 		c.setCookies(cookies)
 	} else if bodyString != "Ok." {
-		return errors.New("bad credentials")
+		return errors.Wrap(ErrLoginFailed, "bad credentials")
 	}
 
-	c.log.Printf("logged into client: %v", c.cfg.Host)
+	c.log.Debug("logged into client", "host", c.cfg.Host)
 
 	return nil
 }
@@ -118,7 +119,7 @@ func (c *Client) setApiVersion() error {
 		return errors.Wrap(err, "could not get webapi version")
 	}
 
-	c.log.Printf("webapi version: %v", versionString)
+	c.log.Debug("webapi version", "version", versionString)
 
 	ver, err := semver.NewVersion(versionString)
 	if err != nil {
@@ -225,6 +226,30 @@ func (c *Client) GetTorrents(o TorrentFilterOptions) ([]Torrent, error) {
 }
 
 func (c *Client) GetTorrentsCtx(ctx context.Context, o TorrentFilterOptions) ([]Torrent, error) {
+	opts := o.params()
+
+	resp, err := c.getCtx(ctx, "torrents/info", opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "get torrents error")
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read body")
+	}
+
+	var torrents []Torrent
+	if err := json.Unmarshal(body, &torrents); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal body")
+	}
+
+	return torrents, nil
+}
+
+// params builds the query parameters shared by torrents/info and torrents/count.
+func (o TorrentFilterOptions) params() map[string]string {
 	opts := map[string]string{}
 
 	if o.Reverse {
@@ -264,24 +289,60 @@ func (c *Client) GetTorrentsCtx(ctx context.Context, o TorrentFilterOptions) ([]
 		opts["includeTrackers"] = strconv.FormatBool(o.IncludeTrackers)
 	}
 
-	resp, err := c.getCtx(ctx, "torrents/info", opts)
+	return opts
+}
+
+// GetTorrentsCount get the number of torrents matching the filter options.
+func (c *Client) GetTorrentsCount(o TorrentFilterOptions) (int, error) {
+	return c.GetTorrentsCountCtx(context.Background(), o)
+}
+
+// GetTorrentsCountCtx get the number of torrents matching the filter options.
+// Limit, Offset, Sort and IncludeTrackers are ignored.
+//
+// Servers older than WebAPI 2.11.4 have no torrents/count; there the count is taken by
+// paging through torrents/info, which is slow but keeps memory use flat.
+func (c *Client) GetTorrentsCountCtx(ctx context.Context, o TorrentFilterOptions) (int, error) {
+	o.Limit, o.Offset, o.Sort, o.Reverse, o.IncludeTrackers = 0, 0, "", false, false
+
+	if ok, err := c.RequiresMinVersion(semver.MustParse("2.11.4")); !ok {
+		if !errors.Is(err, ErrUnsupportedVersion) {
+			return 0, err
+		}
+
+		count := 0
+		for _, err := range c.TorrentsIterCtx(ctx, o, 0) {
+			if err != nil {
+				return 0, err
+			}
+			count++
+		}
+
+		return count, nil
+	}
+
+	resp, err := c.getCtx(ctx, "torrents/count", o.params())
 	if err != nil {
-		return nil, errors.Wrap(err, "get torrents error")
+		return 0, errors.Wrap(err, "could not get torrents count")
 	}
 
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.New("could not get torrents count, unexpected status: %v", resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not read body")
+		return 0, errors.Wrap(err, "could not read body")
 	}
 
-	var torrents []Torrent
-	if err := json.Unmarshal(body, &torrents); err != nil {
-		return nil, errors.Wrap(err, "could not unmarshal body")
+	count, err := strconv.Atoi(strings.TrimSpace(string(body)))
+	if err != nil {
+		return 0, errors.Wrap(err, "could not parse torrents count")
 	}
 
-	return torrents, nil
+	return count, nil
 }
 
 func (c *Client) GetTorrentsActiveDownloads() ([]Torrent, error) {
@@ -371,14 +432,6 @@ func (c *Client) GetTorrentTrackersCtx(ctx context.Context, hash string) ([]Torr
 
 	defer resp.Body.Close()
 
-	dump, err := httputil.DumpResponse(resp, true)
-	if err != nil {
-		// c.log.Printf("get torrent trackers error dump response: %v\n", string(dump))
-		return nil, errors.Wrap(err, "could not dump response for hash: %v", hash)
-	}
-
-	c.log.Printf("get torrent trackers response dump: %q", dump)
-
 	if resp.StatusCode == http.StatusNotFound {
 		return nil, nil
 	} else if resp.StatusCode == http.StatusForbidden {
@@ -390,8 +443,6 @@ func (c *Client) GetTorrentTrackersCtx(ctx context.Context, hash string) ([]Torr
 		return nil, errors.Wrap(err, "could not read body")
 	}
 
-	c.log.Printf("get torrent trackers body: %v\n", string(body))
-
 	var trackers []TorrentTracker
 	if err := json.Unmarshal(body, &trackers); err != nil {
 		return nil, errors.Wrap(err, "could not unmarshal body")
@@ -451,7 +502,22 @@ func (c *Client) AddTorrentFromUrlCtx(ctx context.Context, url string, options m
 		return errors.New("no torrent url provided")
 	}
 
-	options["urls"] = url
+	// rewrite the infohashes of magnets to lowercase hex, so callers can match
+	// them against Torrent.Hash; anything else is left to qBittorrent
+	urls := strings.Split(url, "\n")
+	for i, u := range urls {
+		if !magnet.IsMagnet(strings.TrimSpace(u)) {
+			continue
+		}
+
+		if _, err := magnet.Parse(strings.TrimSpace(u)); err != nil {
+			return errors.Wrap(err, "could not add torrent %v", u)
+		}
+
+		urls[i] = magnet.LowercaseInfoHashes(u)
+	}
+
+	options["urls"] = strings.Join(urls, "\n")
 
 	res, err := c.postCtx(ctx, "torrents/add", options)
 	if err != nil {
@@ -787,6 +853,110 @@ func (c *Client) SetLocationCtx(ctx context.Context, hashes []string, location s
 	return nil
 }
 
+// SetSavePath set the save path of torrents without moving their data.
+// Requires WebAPI 2.8.4 or newer.
+func (c *Client) SetSavePath(hashes []string, path string) error {
+	return c.SetSavePathCtx(context.Background(), hashes, path)
+}
+
+// SetSavePathCtx set the save path of torrents without moving their data.
+// Requires WebAPI 2.8.4 or newer.
+func (c *Client) SetSavePathCtx(ctx context.Context, hashes []string, path string) error {
+	return c.setTorrentPathCtx(ctx, "torrents/setSavePath", hashes, path)
+}
+
+// SetDownloadPath set the download path used for incomplete torrents without moving their data.
+// Requires WebAPI 2.8.4 or newer.
+func (c *Client) SetDownloadPath(hashes []string, path string) error {
+	return c.SetDownloadPathCtx(context.Background(), hashes, path)
+}
+
+// SetDownloadPathCtx set the download path used for incomplete torrents without moving their data.
+// Requires WebAPI 2.8.4 or newer.
+func (c *Client) SetDownloadPathCtx(ctx context.Context, hashes []string, path string) error {
+	return c.setTorrentPathCtx(ctx, "torrents/setDownloadPath", hashes, path)
+}
+
+func (c *Client) setTorrentPathCtx(ctx context.Context, endpoint string, hashes []string, path string) error {
+	if ok, err := c.RequiresMinVersion(semver.MustParse("2.8.4")); !ok {
+		return errors.Wrap(err, "could not %v", endpoint)
+	}
+
+	opts := map[string]string{
+		"id":   strings.Join(hashes, "|"),
+		"path": path,
+	}
+
+	resp, err := c.postCtx(ctx, endpoint, opts)
+	if err != nil {
+		return errors.Wrap(err, "could not %v torrents: %v", endpoint, hashes)
+	}
+
+	defer resp.Body.Close()
+
+	/*
+		HTTP Status Code 	Scenario
+		400 	Save path is empty
+		403 	User does not have write access to the directory
+		409 	Unable to create save path directory
+		200 	All other scenarios
+	*/
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusBadRequest:
+		return errors.New("path is empty")
+	case http.StatusForbidden:
+		return errors.New("no write access to path: %v", path)
+	case http.StatusConflict:
+		return errors.New("unable to create path: %v", path)
+	default:
+		return errors.New("could not %v torrents: %v unexpected status: %v", endpoint, hashes, resp.StatusCode)
+	}
+}
+
+// MoveAndWait move torrents with SetLocation and wait until they finished moving.
+func (c *Client) MoveAndWait(hashes []string, location string) error {
+	return c.MoveAndWaitCtx(context.Background(), hashes, location)
+}
+
+// MoveAndWaitCtx move torrents with SetLocation and poll until none of them is in
+// TorrentStateMoving anymore. It fails if a torrent ends up in TorrentStateMissingFiles
+// or TorrentStateError, or when ctx is done.
+func (c *Client) MoveAndWaitCtx(ctx context.Context, hashes []string, location string) error {
+	if err := c.SetLocationCtx(ctx, hashes, location); err != nil {
+		return err
+	}
+
+	for {
+		// qBittorrent queues the move, give it a moment to pick it up
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "torrents still moving: %v", hashes)
+		case <-time.After(MovePollInterval):
+		}
+
+		torrents, err := c.GetTorrentsCtx(ctx, TorrentFilterOptions{Hashes: hashes})
+		if err != nil {
+			return errors.Wrap(err, "could not get torrents: %v", hashes)
+		}
+
+		moving := false
+		for _, torrent := range torrents {
+			switch torrent.State {
+			case TorrentStateMoving:
+				moving = true
+			case TorrentStateMissingFiles, TorrentStateError:
+				return errors.New("torrent %v ended in state %v after move to %v", torrent.Hash, torrent.State, location)
+			}
+		}
+
+		if !moving {
+			return nil
+		}
+	}
+}
+
 func (c *Client) CreateCategory(category string, path string) error {
 	return c.CreateCategoryCtx(context.Background(), category, path)
 }
@@ -1000,6 +1170,26 @@ func (c *Client) ExportTorrentCtx(ctx context.Context, hash string) ([]byte, err
 	return io.ReadAll(resp.Body)
 }
 
+// ExportTorrentMetaInfo export the .torrent file of a torrent and parse it.
+func (c *Client) ExportTorrentMetaInfo(hash string) (*metainfo.MetaInfo, error) {
+	return c.ExportTorrentMetaInfoCtx(context.Background(), hash)
+}
+
+// ExportTorrentMetaInfoCtx export the .torrent file of a torrent and parse it.
+func (c *Client) ExportTorrentMetaInfoCtx(ctx context.Context, hash string) (*metainfo.MetaInfo, error) {
+	buf, err := c.ExportTorrentCtx(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := metainfo.ParseTorrent(buf)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse exported torrent: %v", hash)
+	}
+
+	return m, nil
+}
+
 func (c *Client) RenameFile(hash, oldPath, newPath string) error {
 	return c.RenameFileCtx(context.Background(), hash, oldPath, newPath)
 }
@@ -2199,6 +2389,9 @@ func (c *Client) RequiresMinVersion(minVersion *semver.Version) (bool, error) {
 	return true, nil
 }
 
+// MovePollInterval is how often MoveAndWaitCtx checks whether torrents are still moving.
+var MovePollInterval = 1 * time.Second
+
 const (
 	ReannounceMaxAttempts = 50
 	ReannounceInterval    = 7 // interval in seconds
@@ -2232,7 +2425,7 @@ func (c *Client) ReannounceTorrentWithRetry(ctx context.Context, hash string, op
 	attempts := 0
 
 	for attempts < maxAttempts {
-		c.log.Printf("re-announce %s attempt: %d", hash, attempts)
+		c.log.Debug("re-announce attempt", "hash", hash, "attempt", attempts)
 
 		// add delay for next run
 		time.Sleep(time.Duration(interval) * time.Second)
@@ -2247,17 +2440,17 @@ func (c *Client) ReannounceTorrentWithRetry(ctx context.Context, hash string, op
 			continue
 		}
 
-		c.log.Printf("re-announce %s attempt: %d trackers (%+v)", hash, attempts, trackers)
+		c.log.Debug("re-announce trackers", "hash", hash, "attempt", attempts, "trackers", len(trackers))
 
 		// check if status not working or something else
 		if isTrackerStatusOK(trackers) {
-			c.log.Printf("re-announce for %v OK", hash)
+			c.log.Debug("re-announce OK", "hash", hash)
 
 			// if working lets return
 			return nil
 		}
 
-		c.log.Printf("not working yet, lets re-announce %s attempt: %d", hash, attempts)
+		c.log.Debug("trackers not working yet, re-announcing", "hash", hash, "attempt", attempts)
 
 		if err = c.ReAnnounceTorrentsCtx(ctx, []string{hash}); err != nil {
 			return errors.Wrap(err, "could not re-announce torrent with hash: %s", hash)
@@ -2268,7 +2461,7 @@ func (c *Client) ReannounceTorrentWithRetry(ctx context.Context, hash string, op
 
 	// delete on failure to reannounce
 	if deleteOnFailure {
-		c.log.Printf("re-announce for %s took too long, deleting torrent", hash)
+		c.log.Info("re-announce took too long, deleting torrent", "hash", hash)
 
 		if err := c.DeleteTorrentsCtx(ctx, []string{hash}, false); err != nil {
 			return errors.Wrap(err, "could not delete torrent with hash: %s", hash)
@@ -2308,15 +2501,5 @@ func isTrackerStatusOK(trackers []TorrentTracker) bool {
 }
 
 func isUnregistered(msg string) bool {
-	words := []string{"unregistered", "not registered", "not found", "not exist"}
-
-	msg = strings.ToLower(msg)
-
-	for _, v := range words {
-		if strings.Contains(msg, v) {
-			return true
-		}
-	}
-
-	return false
+	return defaultTrackerMessageClassifier.Classify("", msg) == TrackerMessageUnregistered
 }
@@ -0,0 +1,260 @@
+// Package metrics exposes qBittorrent and client metrics in the OpenMetrics text format,
+// which Prometheus scrapes natively.
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/autobrr/go-qbittorrent"
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// ContentType is the content type of the output of Collector.
+const ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+type Options struct {
+	// Namespace prefixes every metric name. Defaults to "qbittorrent".
+	Namespace string
+
+	// DisableTorrentMetrics drops the per-torrent metrics, which add a series per torrent.
+	DisableTorrentMetrics bool
+}
+
+// Collector syncs with qBittorrent on every collection and writes its metrics. The sync
+// is incremental, so a scrape only transfers what changed since the previous one.
+type Collector struct {
+	client *qbittorrent.Client
+	cache  *qbittorrent.SyncCache
+	opts   Options
+
+	// collections are serialized, the cache must not sync concurrently
+	mu sync.Mutex
+}
+
+func NewCollector(c *qbittorrent.Client, opts Options) *Collector {
+	if opts.Namespace == "" {
+		opts.Namespace = "qbittorrent"
+	}
+
+	return &Collector{
+		client: c,
+		cache:  qbittorrent.NewSyncCache(c, qbittorrent.SyncCacheOptions{}),
+		opts:   opts,
+	}
+}
+
+// Collect syncs and writes all metrics to w. If qBittorrent cannot be reached the up
+// metric is 0, the client metrics are still written and the error is returned.
+func (c *Collector) Collect(ctx context.Context, w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	out := newWriter(w, c.opts.Namespace)
+
+	syncErr := c.cache.Sync(ctx)
+
+	out.gauge("up", "Whether the last sync with qBittorrent succeeded.", boolValue(syncErr == nil))
+
+	if syncErr == nil {
+		torrents := c.cache.Torrents()
+
+		var categories []string
+		for name := range c.cache.Categories() {
+			categories = append(categories, name)
+		}
+
+		writeServerState(out, c.cache.ServerState())
+		writeAggregates(out, "category", torrents, categories, func(t qbittorrent.Torrent) string { return t.Category })
+		writeAggregates(out, "tracker", torrents, nil, func(t qbittorrent.Torrent) string { return trackerHost(t.Tracker) })
+
+		if !c.opts.DisableTorrentMetrics {
+			writeTorrents(out, torrents)
+		}
+	}
+
+	writeClientStats(out, c.client.Stats())
+
+	if err := out.close(); err != nil {
+		return errors.Wrap(err, "could not write metrics")
+	}
+
+	if syncErr != nil {
+		return errors.Wrap(syncErr, "could not collect metrics")
+	}
+
+	return nil
+}
+
+// ServeHTTP serves the metrics. Sync errors are reported through the up metric.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = c.Collect(r.Context(), w)
+}
+
+func writeServerState(out *writer, s qbittorrent.ServerState) {
+	out.gauge("download_speed_bytes", "Current download speed in bytes per second.", float64(s.DlInfoSpeed))
+	out.gauge("upload_speed_bytes", "Current upload speed in bytes per second.", float64(s.UpInfoSpeed))
+	out.gauge("download_limit_bytes", "Global download speed limit in bytes per second, 0 if unlimited.", float64(s.DlRateLimit))
+	out.gauge("upload_limit_bytes", "Global upload speed limit in bytes per second, 0 if unlimited.", float64(s.UpRateLimit))
+	out.counter("session_downloaded_bytes", "Bytes downloaded since qBittorrent started.", float64(s.DlInfoData))
+	out.counter("session_uploaded_bytes", "Bytes uploaded since qBittorrent started.", float64(s.UpInfoData))
+	out.counter("alltime_downloaded_bytes", "Bytes downloaded over all time.", float64(s.AlltimeDl))
+	out.counter("alltime_uploaded_bytes", "Bytes uploaded over all time.", float64(s.AlltimeUl))
+	out.counter("session_wasted_bytes", "Bytes wasted since qBittorrent started.", float64(s.TotalWastedSession))
+	out.gauge("global_ratio", "All-time share ratio.", s.GlobalRatio)
+	out.gauge("free_space_on_disk_bytes", "Free space in the default save path.", float64(s.FreeSpaceOnDisk))
+	out.gauge("dht_nodes", "Connected DHT nodes.", float64(s.DhtNodes))
+	out.gauge("peer_connections", "Connected peers.", float64(s.TotalPeerConnections))
+	out.gauge("queued_io_jobs", "Queued disk io jobs.", float64(s.QueuedIoJobs))
+	out.gauge("read_cache_hits", "Read cache hits in percent.", s.ReadCacheHits)
+	out.gauge("read_cache_overload", "Read cache overload in percent.", s.ReadCacheOverload)
+	out.gauge("write_cache_overload", "Write cache overload in percent.", s.WriteCacheOverload)
+	out.gauge("alt_speed_limits_enabled", "Whether the alternative speed limits are in use.", boolValue(s.UseAltSpeedLimits))
+
+	out.family("connection_status", "gauge", "Connection status, 1 for the current one.")
+	for _, status := range []string{"connected", "firewalled", "disconnected"} {
+		out.sample("connection_status", boolValue(s.ConnectionStatus == status), "status", status)
+	}
+}
+
+// writeAggregates writes torrent totals grouped by key, including the known keys without
+// any torrents. Torrents with an empty key are skipped unless kind is category, where it
+// means uncategorized.
+func writeAggregates(out *writer, kind string, torrents []qbittorrent.Torrent, known []string, key func(qbittorrent.Torrent) string) {
+	type aggregate struct {
+		torrents, size, downloaded, uploaded, dlSpeed, upSpeed float64
+	}
+
+	groups := make(map[string]*aggregate)
+	for _, k := range known {
+		groups[k] = &aggregate{}
+	}
+
+	for _, t := range torrents {
+		k := key(t)
+		if k == "" && kind != "category" {
+			continue
+		}
+
+		g := groups[k]
+		if g == nil {
+			g = &aggregate{}
+			groups[k] = g
+		}
+
+		g.torrents++
+		g.size += float64(t.Size)
+		g.downloaded += float64(t.Downloaded)
+		g.uploaded += float64(t.Uploaded)
+		g.dlSpeed += float64(t.DlSpeed)
+		g.upSpeed += float64(t.UpSpeed)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, m := range []struct {
+		name, help string
+		value      func(*aggregate) float64
+	}{
+		{"torrents", "Torrents per " + kind + ".", func(a *aggregate) float64 { return a.torrents }},
+		{"size_bytes", "Selected size of the torrents per " + kind + ".", func(a *aggregate) float64 { return a.size }},
+		{"downloaded_bytes", "Bytes downloaded by the torrents per " + kind + ".", func(a *aggregate) float64 { return a.downloaded }},
+		{"uploaded_bytes", "Bytes uploaded by the torrents per " + kind + ".", func(a *aggregate) float64 { return a.uploaded }},
+		{"download_speed_bytes", "Download speed of the torrents per " + kind + " in bytes per second.", func(a *aggregate) float64 { return a.dlSpeed }},
+		{"upload_speed_bytes", "Upload speed of the torrents per " + kind + " in bytes per second.", func(a *aggregate) float64 { return a.upSpeed }},
+	} {
+		name := kind + "_" + m.name
+
+		// totals drop when torrents are removed, so they are gauges
+		out.family(name, "gauge", m.help)
+		for _, k := range keys {
+			out.sample(name, m.value(groups[k]), kind, k)
+		}
+	}
+}
+
+func writeTorrents(out *writer, torrents []qbittorrent.Torrent) {
+	for _, m := range []struct {
+		name, typ, help string
+		value           func(qbittorrent.Torrent) float64
+	}{
+		{"torrent_size_bytes", "gauge", "Selected size of the torrent.", func(t qbittorrent.Torrent) float64 { return float64(t.Size) }},
+		{"torrent_progress", "gauge", "Progress of the torrent from 0 to 1.", func(t qbittorrent.Torrent) float64 { return t.Progress }},
+		{"torrent_ratio", "gauge", "Share ratio of the torrent.", func(t qbittorrent.Torrent) float64 { return t.Ratio }},
+		{"torrent_download_speed_bytes", "gauge", "Download speed of the torrent in bytes per second.", func(t qbittorrent.Torrent) float64 { return float64(t.DlSpeed) }},
+		{"torrent_upload_speed_bytes", "gauge", "Upload speed of the torrent in bytes per second.", func(t qbittorrent.Torrent) float64 { return float64(t.UpSpeed) }},
+		{"torrent_seeders", "gauge", "Seeders connected to the torrent.", func(t qbittorrent.Torrent) float64 { return float64(t.NumSeeds) }},
+		{"torrent_leechers", "gauge", "Leechers connected to the torrent.", func(t qbittorrent.Torrent) float64 { return float64(t.NumLeechs) }},
+		{"torrent_downloaded_bytes", "counter", "Bytes downloaded by the torrent.", func(t qbittorrent.Torrent) float64 { return float64(t.Downloaded) }},
+		{"torrent_uploaded_bytes", "counter", "Bytes uploaded by the torrent.", func(t qbittorrent.Torrent) float64 { return float64(t.Uploaded) }},
+	} {
+		sample := m.name
+		if m.typ == "counter" {
+			sample += "_total"
+		}
+
+		out.family(m.name, m.typ, m.help)
+		for _, t := range torrents {
+			out.sample(sample, m.value(t), "hash", t.Hash, "name", t.Name, "category", t.Category)
+		}
+	}
+
+	out.family("torrent_state", "gauge", "State of the torrent, the current state is 1.")
+	for _, t := range torrents {
+		out.sample("torrent_state", 1, "hash", t.Hash, "name", t.Name, "state", string(t.State))
+	}
+}
+
+func writeClientStats(out *writer, stats qbittorrent.ClientStats) {
+	endpoints := make([]string, 0, len(stats.Endpoints))
+	for endpoint := range stats.Endpoints {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Strings(endpoints)
+
+	out.family("client_requests", "counter", "WebAPI requests made by the client.")
+	for _, endpoint := range endpoints {
+		out.sample("client_requests_total", float64(stats.Endpoints[endpoint].Requests), "endpoint", endpoint)
+	}
+
+	out.family("client_request_errors", "counter", "WebAPI requests that failed after all retries.")
+	for _, endpoint := range endpoints {
+		out.sample("client_request_errors_total", float64(stats.Endpoints[endpoint].Errors), "endpoint", endpoint)
+	}
+
+	out.family("client_request_duration_seconds", "histogram", "Duration of WebAPI requests including retries.")
+	for _, endpoint := range endpoints {
+		e := stats.Endpoints[endpoint]
+
+		for i, bound := range e.Bounds {
+			out.sample("client_request_duration_seconds_bucket", float64(e.Buckets[i]), "endpoint", endpoint, "le", formatValue(bound.Seconds()))
+		}
+
+		out.sample("client_request_duration_seconds_bucket", float64(e.Requests), "endpoint", endpoint, "le", "+Inf")
+		out.sample("client_request_duration_seconds_sum", e.Duration.Seconds(), "endpoint", endpoint)
+		out.sample("client_request_duration_seconds_count", float64(e.Requests), "endpoint", endpoint)
+	}
+
+	out.counter("client_retries", "WebAPI requests attempted again after a failure.", float64(stats.Retries))
+	out.counter("client_relogins", "Logins caused by the session expiring during a request.", float64(stats.Relogins))
+}
+
+// trackerHost returns the lower case host of a tracker url without its port.
+func trackerHost(tracker string) string {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+func newTestServer(t *testing.T, maindata string) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/app/webapiVersion", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("2.11.4"))
+	})
+	mux.HandleFunc("/api/v2/sync/maindata", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(maindata))
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestCollector_Collect(t *testing.T) {
+	srv := newTestServer(t, `{"rid":1,"full_update":true,
+		"torrents":{
+			"a":{"name":"Show \"S01\"","category":"tv","size":100,"progress":0.5,"ratio":1.5,"dlspeed":10,"upspeed":20,"num_seeds":3,"state":"downloading","tracker":"https://tracker.example:443/announce","uploaded":150},
+			"b":{"name":"b","size":200,"progress":1,"state":"uploading","tracker":"https://tracker.example/announce"}
+		},
+		"categories":{"tv":{"name":"tv"},"empty":{"name":"empty"}},
+		"server_state":{"dl_info_speed":10,"up_info_speed":20,"alltime_ul":1000,"global_ratio":"1.25","connection_status":"connected"}}`)
+
+	client := qbittorrent.NewClient(qbittorrent.Config{Host: srv.URL})
+	collector := NewCollector(client, Options{})
+
+	var buf bytes.Buffer
+	assert.NoError(t, collector.Collect(context.Background(), &buf))
+
+	out := buf.String()
+	for _, line := range []string{
+		`qbittorrent_up 1`,
+		`qbittorrent_download_speed_bytes 10`,
+		`qbittorrent_alltime_uploaded_bytes_total 1000`,
+		`qbittorrent_global_ratio 1.25`,
+		`qbittorrent_connection_status{status="connected"} 1`,
+		`qbittorrent_category_torrents{category=""} 1`,
+		`qbittorrent_category_torrents{category="empty"} 0`,
+		`qbittorrent_category_size_bytes{category="tv"} 100`,
+		`qbittorrent_tracker_torrents{tracker="tracker.example"} 2`,
+		`qbittorrent_torrent_progress{hash="a",name="Show \"S01\"",category="tv"} 0.5`,
+		`qbittorrent_torrent_uploaded_bytes_total{hash="a",name="Show \"S01\"",category="tv"} 150`,
+		`qbittorrent_torrent_state{hash="b",name="b",state="uploading"} 1`,
+		`qbittorrent_client_requests_total{endpoint="sync/maindata"} 1`,
+		`qbittorrent_client_request_duration_seconds_count{endpoint="sync/maindata"} 1`,
+		`qbittorrent_client_request_duration_seconds_bucket{endpoint="sync/maindata",le="+Inf"} 1`,
+		`qbittorrent_client_retries_total 0`,
+		`# TYPE qbittorrent_client_relogins counter`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+
+	assert.True(t, strings.HasSuffix(out, "# EOF\n"))
+}
+
+func TestCollector_Unreachable(t *testing.T) {
+	srv := newTestServer(t, `not json`)
+
+	client := qbittorrent.NewClient(qbittorrent.Config{Host: srv.URL})
+	collector := NewCollector(client, Options{Namespace: "qbit", DisableTorrentMetrics: true})
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	out := rec.Body.String()
+	assert.Equal(t, ContentType, rec.Header().Get("Content-Type"))
+	assert.Contains(t, out, "qbit_up 0\n")
+	assert.Contains(t, out, "qbit_client_requests_total")
+	assert.NotContains(t, out, "qbit_torrent_")
+	assert.True(t, strings.HasSuffix(out, "# EOF\n"))
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// writer writes the OpenMetrics text format. Every family must be written with all of
+// its samples before the next one starts.
+type writer struct {
+	w  *bufio.Writer
+	ns string
+}
+
+func newWriter(w io.Writer, namespace string) *writer {
+	return &writer{w: bufio.NewWriter(w), ns: namespace}
+}
+
+// family starts a metric family of the given type, e.g. "gauge" or "counter".
+func (w *writer) family(name, typ, help string) {
+	name = w.ns + "_" + name
+
+	w.w.WriteString("# TYPE " + name + " " + typ + "\n")
+	w.w.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+}
+
+// sample writes one sample; labels are pairs of name and value.
+func (w *writer) sample(name string, value float64, labels ...string) {
+	w.w.WriteString(w.ns + "_" + name)
+
+	if len(labels) > 0 {
+		w.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.w.WriteByte('}')
+	}
+
+	w.w.WriteString(" " + formatValue(value) + "\n")
+}
+
+// gauge writes a family with a single unlabelled gauge sample.
+func (w *writer) gauge(name, help string, value float64) {
+	w.family(name, "gauge", help)
+	w.sample(name, value)
+}
+
+// counter writes a family with a single unlabelled counter sample.
+func (w *writer) counter(name, help string, value float64) {
+	w.family(name, "counter", help)
+	w.sample(name+"_total", value)
+}
+
+func (w *writer) close() error {
+	w.w.WriteString("# EOF\n")
+	return w.w.Flush()
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,336 @@
+package qbittorrent
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// MigratePollInterval is how often MigrateTorrentCtx checks the torrent on the target.
+var MigratePollInterval = 1 * time.Second
+
+// DefaultMigrateVerifyTimeout is how long MigrateTorrentCtx waits for the target to verify
+// a torrent, unless MigrateOptions.VerifyTimeout is set.
+const DefaultMigrateVerifyTimeout = 10 * time.Minute
+
+// inactiveSeedingTimeLimitMinVersion is the first WebAPI version with the inactive
+// seeding time limit, older ones leave Torrent.InactiveSeedingTimeLimit at 0.
+var inactiveSeedingTimeLimitMinVersion = semver.MustParse("2.9.2")
+
+type MigrateOptions struct {
+	// SavePath is the save path on the target, the save path on the source if empty.
+	// Needed when the instances mount the data at different paths.
+	SavePath string
+
+	// SkipChecking adds the torrent without a hash check. Only use it if the target sees
+	// the same, complete data as the source.
+	SkipChecking bool
+
+	// KeepSource leaves the torrent on the source, copying it instead of moving it. An
+	// incomplete torrent is then left stopped on the target, so only one instance
+	// writes to its files.
+	KeepSource bool
+
+	// VerifyTimeout bounds the wait for the target to check the torrent, defaults to
+	// DefaultMigrateVerifyTimeout.
+	VerifyTimeout time.Duration
+}
+
+// migration is a single MigrateTorrentCtx, undone in reverse by rollback.
+type migration struct {
+	src, dst *Client
+	hash     string
+	opts     MigrateOptions
+
+	torrent   Torrent
+	stopped   bool // by the migration, on the source
+	added     bool // on the target
+	rechecked bool // on the target
+}
+
+// MigrateTorrentCtx moves a torrent from src to dst. The torrent is exported from src,
+// stopped there and added stopped to dst with the same category, tags, save path and
+// share limits, then rechecked. Once dst has checked it and has at least the progress src
+// had, it is started on dst if it was running on src and deleted from src; its files are
+// never deleted.
+//
+// If any step fails the migration is rolled back: the torrent is deleted from dst, again
+// without its files, and started on src if the migration stopped it.
+func MigrateTorrentCtx(ctx context.Context, src, dst *Client, hash string, opts MigrateOptions) error {
+	m := &migration{src: src, dst: dst, hash: strings.ToLower(hash), opts: opts}
+
+	if err := m.run(ctx); err != nil {
+		// roll back even if ctx is what failed
+		if rerr := m.rollback(context.WithoutCancel(ctx)); rerr != nil {
+			return errors.Wrap(err, "could not migrate torrent %v, rollback failed too: %v", hash, rerr)
+		}
+
+		return errors.Wrap(err, "could not migrate torrent %v", hash)
+	}
+
+	return nil
+}
+
+func (m *migration) run(ctx context.Context) error {
+	torrent, found, err := getTorrent(ctx, m.src, m.hash)
+	if err != nil {
+		return errors.Wrap(err, "could not get torrent from source")
+	} else if !found {
+		return errors.New("torrent not found on source")
+	}
+	m.torrent = torrent
+
+	if _, found, err := getTorrent(ctx, m.dst, m.hash); err != nil {
+		return errors.Wrap(err, "could not get torrent from target")
+	} else if found {
+		return errors.New("torrent already on target")
+	}
+
+	buf, err := m.src.ExportTorrentCtx(ctx, m.hash)
+	if err != nil {
+		return errors.Wrap(err, "could not export torrent")
+	}
+
+	// the instances must not both write to the files
+	if !isStopped(torrent.State) {
+		if err := m.src.StopCtx(ctx, []string{m.hash}); err != nil {
+			return errors.Wrap(err, "could not stop torrent on source")
+		}
+		m.stopped = true
+	}
+
+	if err := m.dst.AddTorrentFromMemoryCtx(ctx, buf, m.addOptions()); err != nil {
+		return errors.Wrap(err, "could not add torrent to target")
+	}
+	m.added = true
+
+	if err := m.verify(ctx); err != nil {
+		return err
+	}
+
+	// the instances must not both write to the files
+	if m.stopped && (!m.opts.KeepSource || m.torrent.Progress >= 1) {
+		if err := m.dst.StartCtx(ctx, []string{m.hash}); err != nil {
+			return errors.Wrap(err, "could not start torrent on target")
+		}
+	}
+
+	if m.opts.KeepSource {
+		if m.stopped {
+			if err := m.src.StartCtx(ctx, []string{m.hash}); err != nil {
+				return errors.Wrap(err, "could not start torrent on source")
+			}
+		}
+
+		return nil
+	}
+
+	if err := m.src.DeleteTorrentsCtx(ctx, []string{m.hash}, false); err != nil {
+		return errors.Wrap(err, "could not delete torrent from source")
+	}
+
+	return nil
+}
+
+func (m *migration) addOptions() map[string]string {
+	t := m.torrent
+
+	savePath := m.opts.SavePath
+	if savePath == "" {
+		savePath = t.SavePath
+	}
+
+	options := map[string]string{
+		"savepath":         savePath,
+		"autoTMM":          "false",
+		"ratioLimit":       strconv.FormatFloat(t.RatioLimit, 'f', 2, 64),
+		"seedingTimeLimit": strconv.FormatInt(t.SeedingTimeLimit, 10),
+		// started once verified
+		"paused":  "true",
+		"stopped": "true",
+	}
+
+	if t.Category != "" {
+		options["category"] = t.Category
+	}
+	if t.Tags != "" {
+		options["tags"] = t.Tags
+	}
+	if t.UpLimit > 0 {
+		options["upLimit"] = strconv.FormatInt(t.UpLimit, 10)
+	}
+	if t.DlLimit > 0 {
+		options["dlLimit"] = strconv.FormatInt(t.DlLimit, 10)
+	}
+	if ok, _ := m.src.RequiresMinVersion(inactiveSeedingTimeLimitMinVersion); ok {
+		options["inactiveSeedingTimeLimit"] = strconv.FormatInt(t.InactiveSeedingTimeLimit, 10)
+	}
+	if m.opts.SkipChecking {
+		options["skip_checking"] = "true"
+	}
+
+	return options
+}
+
+// verify rechecks the torrent on the target, where it was added stopped and would not be
+// checked otherwise, unless SkipChecking is set. It then waits until the target has
+// checked it and found at least the progress the source had. Until a checking state was
+// seen, a lower progress is taken for the state before the check.
+func (m *migration) verify(ctx context.Context) error {
+	timeout := m.opts.VerifyTimeout
+	if timeout <= 0 {
+		timeout = DefaultMigrateVerifyTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	checked := false
+
+	for {
+		t, found, err := getTorrent(ctx, m.dst, m.hash)
+		if err != nil {
+			return errors.Wrap(err, "could not verify torrent on target")
+		}
+
+		switch {
+		case !found:
+			// torrents are added asynchronously
+		case !m.opts.SkipChecking && !m.rechecked:
+			if err := m.dst.RecheckCtx(ctx, []string{m.hash}); err != nil {
+				return errors.Wrap(err, "could not recheck torrent on target")
+			}
+			m.rechecked = true
+		default:
+			switch t.State {
+			case TorrentStateError, TorrentStateMissingFiles:
+				return errors.New("torrent on target is in state %v", t.State)
+			case TorrentStateCheckingUp, TorrentStateCheckingDl, TorrentStateCheckingResumeData:
+				checked = true
+			case TorrentStateMetaDl, TorrentStateAllocating, TorrentStateMoving:
+				// not done yet
+			default:
+				if t.Progress >= m.torrent.Progress {
+					return nil
+				}
+
+				if checked {
+					return errors.New("torrent on target has progress %.4f, source had %.4f", t.Progress, m.torrent.Progress)
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "torrent not verified on target")
+		case <-time.After(MigratePollInterval):
+		}
+	}
+}
+
+func (m *migration) rollback(ctx context.Context) error {
+	if m.added {
+		if err := m.dst.DeleteTorrentsCtx(ctx, []string{m.hash}, false); err != nil {
+			return errors.Wrap(err, "could not delete torrent from target")
+		}
+	}
+
+	if m.stopped {
+		if err := m.src.StartCtx(ctx, []string{m.hash}); err != nil {
+			return errors.Wrap(err, "could not start torrent on source")
+		}
+	}
+
+	return nil
+}
+
+func getTorrent(ctx context.Context, c *Client, hash string) (Torrent, bool, error) {
+	torrents, err := c.GetTorrentsCtx(ctx, TorrentFilterOptions{Hashes: []string{hash}})
+	if err != nil {
+		return Torrent{}, false, err
+	}
+
+	for _, t := range torrents {
+		if strings.EqualFold(t.Hash, hash) {
+			return t, true, nil
+		}
+	}
+
+	return Torrent{}, false, nil
+}
+
+func isStopped(state TorrentState) bool {
+	switch state {
+	case TorrentStatePausedUp, TorrentStatePausedDl, TorrentStateStoppedUp, TorrentStateStoppedDl:
+		return true
+	}
+
+	return false
+}
+
+// MigrateError holds the torrents a bulk migration failed for.
+type MigrateError struct {
+	// Errors is keyed by hash.
+	Errors map[string]error
+}
+
+func (e *MigrateError) Error() string {
+	hashes := make([]string, 0, len(e.Errors))
+	for hash := range e.Errors {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	msgs := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		msgs = append(msgs, e.Errors[hash].Error())
+	}
+
+	return "migrating " + strconv.Itoa(len(hashes)) + " torrents failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *MigrateError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// MigrateTorrentsCtx migrates torrents one at a time with MigrateTorrentCtx, e.g. to
+// rebalance instances. A failed migration is rolled back and the next one is tried; the
+// hashes that were migrated are returned along with a *MigrateError for the rest. It
+// stops when ctx is done.
+func MigrateTorrentsCtx(ctx context.Context, src, dst *Client, hashes []string, opts MigrateOptions) ([]string, error) {
+	var (
+		migrated []string
+		errs     = map[string]error{}
+	)
+
+	for _, hash := range hashes {
+		if err := ctx.Err(); err != nil {
+			errs[hash] = err
+			continue
+		}
+
+		if err := MigrateTorrentCtx(ctx, src, dst, hash, opts); err != nil {
+			errs[hash] = err
+			continue
+		}
+
+		migrated = append(migrated, hash)
+	}
+
+	if len(errs) > 0 {
+		return migrated, &MigrateError{Errors: errs}
+	}
+
+	return migrated, nil
+}
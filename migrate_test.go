@@ -0,0 +1,230 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// migrateTestInstance fakes the endpoints a migration uses and records the calls made.
+type migrateTestInstance struct {
+	mu      sync.Mutex
+	torrent string   // torrents/info entry, empty if absent
+	states  []string // entries overriding torrent, one per request, the last one sticks
+	options map[string]string
+
+	failAdd bool
+	callRecorder
+}
+
+func (m *migrateTestInstance) client(t *testing.T) *Client {
+	return newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			if m.torrent == "" {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+
+			state := `"state":"stalledUP"`
+			if len(m.states) > 0 {
+				state = m.states[0]
+				if len(m.states) > 1 {
+					m.states = m.states[1:]
+				}
+			}
+
+			// later keys win
+			_, _ = w.Write([]byte(`[{"hash":"abc",` + m.torrent + `,` + state + `}]`))
+		},
+		"torrents/export": func(w http.ResponseWriter, r *http.Request) {
+			m.record("export")
+			_, _ = w.Write([]byte("d4:infod4:name1:aee"))
+		},
+		"torrents/stop": func(w http.ResponseWriter, r *http.Request) {
+			m.record("stop")
+		},
+		"torrents/start": func(w http.ResponseWriter, r *http.Request) {
+			m.record("start")
+		},
+		"torrents/recheck": func(w http.ResponseWriter, r *http.Request) {
+			m.record("recheck")
+		},
+		"torrents/add": func(w http.ResponseWriter, r *http.Request) {
+			m.record("add")
+
+			if m.failAdd {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+
+			require.NoError(t, r.ParseMultipartForm(1<<20))
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+
+			m.options = map[string]string{}
+			for k, v := range r.MultipartForm.Value {
+				m.options[k] = v[0]
+			}
+			m.torrent = `"progress":1`
+		},
+		"torrents/delete": func(w http.ResponseWriter, r *http.Request) {
+			m.record("delete " + r.FormValue("hashes") + " " + r.FormValue("deleteFiles"))
+
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.torrent = ""
+		},
+	})
+}
+
+func TestMigrateTorrentCtx(t *testing.T) {
+	setForTest(t, &MigratePollInterval, 10*time.Millisecond)
+
+	const source = `"progress":1,"category":"movies","tags":"a, b","save_path":"/data/movies","ratio_limit":2,"seeding_time_limit":1440,"inactive_seeding_time_limit":60,"up_limit":1024`
+
+	const (
+		added    = `"state":"stoppedDL","progress":0`
+		checking = `"state":"checkingUP","progress":0.5`
+		checked  = `"state":"stoppedUP","progress":1`
+	)
+
+	tests := []struct {
+		name     string
+		srcState string
+		dstState []string
+		failAdd  bool
+		opts     MigrateOptions
+		wantErr  bool
+		wantSrc  []string
+		wantDst  []string
+	}{
+		{
+			name:     "moved",
+			dstState: []string{added, added, `"state":"checkingResumeData"`, checking, checked},
+			wantSrc:  []string{"export", "stop", "delete abc false"},
+			wantDst:  []string{"add", "recheck", "start"},
+		},
+		{
+			// stays stopped
+			name:     "moved_stopped",
+			srcState: `"state":"stoppedUP"`,
+			dstState: []string{added, added, checking, checked},
+			wantSrc:  []string{"export", "delete abc false"},
+			wantDst:  []string{"add", "recheck"},
+		},
+		{
+			name:     "skip_checking",
+			dstState: []string{added, checked},
+			opts:     MigrateOptions{SkipChecking: true},
+			wantSrc:  []string{"export", "stop", "delete abc false"},
+			wantDst:  []string{"add", "start"},
+		},
+		{
+			name:    "kept",
+			opts:    MigrateOptions{KeepSource: true},
+			wantSrc: []string{"export", "stop", "start"},
+			wantDst: []string{"add", "recheck", "start"},
+		},
+		{
+			// only the source downloads
+			name:     "kept_incomplete",
+			srcState: `"progress":0.5`,
+			dstState: []string{added, checking, `"state":"stoppedDL","progress":0.5`},
+			opts:     MigrateOptions{KeepSource: true},
+			wantSrc:  []string{"export", "stop", "start"},
+			wantDst:  []string{"add", "recheck"},
+		},
+		{
+			name:     "incomplete_on_target",
+			dstState: []string{added, checking, `"state":"stoppedDL","progress":0.5`},
+			wantErr:  true,
+			wantSrc:  []string{"export", "stop", "start"},
+			wantDst:  []string{"add", "recheck", "delete abc false"},
+		},
+		{
+			name:     "missing_files",
+			dstState: []string{added, checking, `"state":"missingFiles"`},
+			wantErr:  true,
+			wantSrc:  []string{"export", "stop", "start"},
+			wantDst:  []string{"add", "recheck", "delete abc false"},
+		},
+		{
+			name:    "add_failed",
+			failAdd: true,
+			wantErr: true,
+			wantSrc: []string{"export", "stop", "start"},
+			wantDst: []string{"add"},
+		},
+		{
+			name:     "timeout",
+			dstState: []string{added},
+			opts:     MigrateOptions{VerifyTimeout: 50 * time.Millisecond},
+			wantErr:  true,
+			wantSrc:  []string{"export", "stop", "start"},
+			wantDst:  []string{"add", "recheck", "delete abc false"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &migrateTestInstance{torrent: source}
+			if tt.srcState != "" {
+				src.states = []string{tt.srcState}
+			}
+			dst := &migrateTestInstance{states: tt.dstState, failAdd: tt.failAdd}
+
+			err := MigrateTorrentCtx(context.Background(), src.client(t), dst.client(t), "ABC", tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.Equal(t, tt.wantSrc, src.calls)
+			assert.Equal(t, tt.wantDst, dst.calls)
+
+			if !tt.failAdd {
+				want := map[string]string{
+					"savepath":                 "/data/movies",
+					"autoTMM":                  "false",
+					"category":                 "movies",
+					"tags":                     "a, b",
+					"ratioLimit":               "2.00",
+					"seedingTimeLimit":         "1440",
+					"inactiveSeedingTimeLimit": "60",
+					"upLimit":                  "1024",
+					"paused":                   "true",
+					"stopped":                  "true",
+				}
+				if tt.opts.SkipChecking {
+					want["skip_checking"] = "true"
+				}
+				assert.Equal(t, want, dst.options)
+			}
+		})
+	}
+}
+
+func TestMigrateTorrentsCtx(t *testing.T) {
+	src := &migrateTestInstance{torrent: `"progress":1`}
+	dst := &migrateTestInstance{torrent: `"progress":1`}
+
+	// already on the target, nothing is changed
+	migrated, err := MigrateTorrentsCtx(context.Background(), src.client(t), dst.client(t), []string{"abc"}, MigrateOptions{})
+
+	var migrateErr *MigrateError
+	require.ErrorAs(t, err, &migrateErr)
+	assert.Contains(t, migrateErr.Errors, "abc")
+	assert.Empty(t, migrated)
+	assert.Empty(t, src.calls)
+	assert.Empty(t, dst.calls)
+}
@@ -0,0 +1,72 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_SetSavePathCtx(t *testing.T) {
+	client := newTestClient(t, "2.8.4", map[string]http.HandlerFunc{
+		"torrents/setSavePath": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "a|b", r.FormValue("id"))
+			if r.FormValue("path") == "/unwritable" {
+				w.WriteHeader(http.StatusConflict)
+			}
+		},
+	})
+
+	assert.NoError(t, client.SetSavePathCtx(context.Background(), []string{"a", "b"}, "/data"))
+	assert.Error(t, client.SetSavePathCtx(context.Background(), []string{"a", "b"}, "/unwritable"))
+}
+
+func TestClient_SetDownloadPathCtx_UnsupportedVersion(t *testing.T) {
+	client := newTestClient(t, "2.8.3", nil)
+
+	err := client.SetDownloadPathCtx(context.Background(), []string{"a"}, "/incomplete")
+	assert.ErrorIs(t, err, ErrUnsupportedVersion)
+}
+
+func TestClient_MoveAndWaitCtx(t *testing.T) {
+	setForTest(t, &MovePollInterval, 10*time.Millisecond)
+
+	tests := []struct {
+		name    string
+		states  []TorrentState
+		wantErr bool
+	}{
+		{name: "moved", states: []TorrentState{TorrentStateMoving, TorrentStateMoving, TorrentStateStalledUp}},
+		{name: "missing_files", states: []TorrentState{TorrentStateMoving, TorrentStateMissingFiles}, wantErr: true},
+		{name: "error", states: []TorrentState{TorrentStateError}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var polls atomic.Int32
+
+			client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+				"torrents/setLocation": func(w http.ResponseWriter, r *http.Request) {
+					assert.Equal(t, "/new", r.FormValue("location"))
+				},
+				"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+					n := int(polls.Add(1)) - 1
+					if n >= len(tt.states) {
+						n = len(tt.states) - 1
+					}
+					_, _ = w.Write([]byte(`[{"hash":"a","state":"` + string(tt.states[n]) + `"}]`))
+				},
+			})
+
+			err := client.MoveAndWaitCtx(context.Background(), []string{"a"}, "/new")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, int32(len(tt.states)), polls.Load())
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package qbittorrent
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// syncStateVersion is bumped when the saved state is no longer compatible.
+const syncStateVersion = 1
+
+var ErrSyncStateMismatch = errors.New("saved sync state does not belong to this client")
+
+// syncState is what SyncCache saves to disk.
+//
+// qBittorrent keeps the last rid per WebUI session and answers any other rid with a full
+// update, so the session cookies are saved alongside the rid; without them resuming would
+// always start over.
+type syncState struct {
+	Version  int           `json:"version"`
+	Host     string        `json:"host"`
+	SavedAt  time.Time     `json:"saved_at"`
+	Cookies  []stateCookie `json:"cookies,omitempty"`
+	MainData MainData      `json:"main_data"`
+}
+
+type stateCookie struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SaveState writes the current state, its rid and the WebUI session to w.
+// The output contains the session cookie and should be kept private.
+func (s *SyncCache) SaveState(w io.Writer) error {
+	s.mu.RLock()
+	state := syncState{
+		Version:  syncStateVersion,
+		Host:     s.client.cfg.Host,
+		SavedAt:  time.Now(),
+		MainData: s.data.clone(),
+	}
+	s.mu.RUnlock()
+
+	for _, cookie := range s.client.http.Jar.Cookies(s.cookieURL()) {
+		state.Cookies = append(state.Cookies, stateCookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		return errors.Wrap(err, "could not encode sync state")
+	}
+
+	return nil
+}
+
+// LoadState replaces the current state with one written by SaveState, so the next sync
+// requests only the changes since it was saved. It returns ErrSyncStateMismatch if the
+// state was saved for another host or by an incompatible version.
+func (s *SyncCache) LoadState(r io.Reader) error {
+	var state syncState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return errors.Wrap(err, "could not decode sync state")
+	}
+
+	if state.Version != syncStateVersion || state.Host != s.client.cfg.Host {
+		return ErrSyncStateMismatch
+	}
+
+	if len(state.Cookies) > 0 {
+		cookies := make([]*http.Cookie, 0, len(state.Cookies))
+		for _, cookie := range state.Cookies {
+			cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+		}
+
+		s.client.setCookies(cookies)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data = state.MainData
+	s.index.update(&s.data, &mainDataDelta{FullUpdate: true})
+
+	return nil
+}
+
+// SaveStateFile writes the state to path, replacing it atomically. The file is only
+// readable by its owner.
+func (s *SyncCache) SaveStateFile(path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "could not create sync state file")
+	}
+
+	defer os.Remove(f.Name())
+
+	if err := f.Chmod(0o600); err != nil {
+		f.Close()
+		return errors.Wrap(err, "could not chmod sync state file")
+	}
+
+	if err := s.SaveState(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return errors.Wrap(err, "could not write sync state file")
+	}
+
+	if err := os.Rename(f.Name(), path); err != nil {
+		return errors.Wrap(err, "could not replace sync state file")
+	}
+
+	return nil
+}
+
+// LoadStateFile loads a state written by SaveStateFile. A missing file returns an error
+// matching os.ErrNotExist.
+func (s *SyncCache) LoadStateFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "could not open sync state file")
+	}
+
+	defer f.Close()
+
+	return s.LoadState(f)
+}
+
+func (s *SyncCache) cookieURL() *url.URL {
+	u, _ := url.Parse(s.client.buildUrl("/", nil))
+	return u
+}
@@ -0,0 +1,87 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCache_StateFile(t *testing.T) {
+	var restarted bool
+
+	handlers := map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			rid := r.URL.Query().Get("rid")
+
+			switch {
+			case rid == "0":
+				_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"torrents":{"a":{"name":"a","category":"tv","progress":0.5}}}`))
+			case restarted:
+				_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"torrents":{"b":{"name":"b"}}}`))
+			case rid == "1":
+				// the session must have been resumed for the rid to be accepted
+				if cookie, err := r.Cookie("SID"); err != nil || cookie.Value != "session" {
+					_, _ = w.Write([]byte(`{"rid":1,"full_update":true}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"rid":2,"torrents":{"a":{"progress":0.6}}}`))
+			default:
+				t.Errorf("unexpected rid: %v", rid)
+			}
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "state.json")
+	ctx := context.Background()
+
+	client := newTestClient(t, "2.11.4", handlers)
+	client.setCookies([]*http.Cookie{{Name: "SID", Value: "session"}})
+
+	cache := NewSyncCache(client, SyncCacheOptions{})
+	assert.NoError(t, cache.Sync(ctx))
+	assert.NoError(t, cache.SaveStateFile(path))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// a new process with a fresh client
+	resumed := NewSyncCache(NewClient(client.cfg), SyncCacheOptions{})
+	assert.NoError(t, resumed.LoadStateFile(path))
+	assert.Equal(t, []string{"a"}, torrentHashes(resumed.ByCategory("tv")))
+
+	var resyncs int
+	resumed.OnResync(func() {
+		resyncs++
+	})
+
+	assert.NoError(t, resumed.Sync(ctx))
+	a, ok := resumed.Torrent("a")
+	assert.True(t, ok)
+	assert.Equal(t, 0.6, a.Progress)
+	assert.Equal(t, "a", a.Name)
+	assert.Equal(t, 0, resyncs)
+
+	// qBittorrent restarted and lost the rid
+	restarted = true
+	assert.NoError(t, resumed.Sync(ctx))
+	assert.Equal(t, 1, resyncs)
+	assert.Equal(t, []string{"b"}, torrentHashes(resumed.Torrents()))
+	assert.Empty(t, resumed.ByCategory("tv"))
+}
+
+func TestSyncCache_LoadStateMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	cache := NewSyncCache(NewClient(Config{Host: "http://a.example"}), SyncCacheOptions{})
+	assert.NoError(t, cache.SaveStateFile(path))
+
+	other := NewSyncCache(NewClient(Config{Host: "http://b.example"}), SyncCacheOptions{})
+	assert.ErrorIs(t, other.LoadStateFile(path), ErrSyncStateMismatch)
+
+	assert.ErrorIs(t, other.LoadStateFile(filepath.Join(t.TempDir(), "missing.json")), os.ErrNotExist)
+}
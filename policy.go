@@ -0,0 +1,390 @@
+package qbittorrent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+type PolicyActionType string
+
+const (
+	PolicyActionStop PolicyActionType = "stop"
+
+	// PolicyActionDelete deletes torrents, with their files if DeleteFiles is set.
+	PolicyActionDelete PolicyActionType = "delete"
+
+	// PolicyActionSetShareLimit sets RatioLimit, SeedingTimeLimit and
+	// InactiveSeedingTimeLimit, see SetTorrentShareLimitCtx. Limits left at 0 are set to
+	// ShareLimitGlobal.
+	PolicyActionSetShareLimit PolicyActionType = "set_share_limit"
+
+	PolicyActionAddTag      PolicyActionType = "add_tag"
+	PolicyActionRemoveTag   PolicyActionType = "remove_tag"
+	PolicyActionSetCategory PolicyActionType = "set_category"
+
+	// PolicyActionSetUploadLimit sets UploadLimit in bytes per second, 0 for no limit.
+	PolicyActionSetUploadLimit PolicyActionType = "set_upload_limit"
+)
+
+// Share limits of PolicyActionSetShareLimit with a special meaning to qBittorrent.
+const (
+	ShareLimitGlobal = -2 // use the global limit
+	ShareLimitNone   = -1 // no limit
+)
+
+type PolicyAction struct {
+	Type PolicyActionType
+
+	DeleteFiles bool
+
+	RatioLimit               float64
+	SeedingTimeLimit         int64 // minutes
+	InactiveSeedingTimeLimit int64 // minutes
+
+	Tag         string
+	Category    string
+	UploadLimit int64
+}
+
+func (a PolicyAction) String() string {
+	switch a.Type {
+	case PolicyActionDelete:
+		if a.DeleteFiles {
+			return "delete with files"
+		}
+		return "delete"
+	case PolicyActionSetShareLimit:
+		ratio, seeding, inactive := a.shareLimits()
+		return fmt.Sprintf("set share limit ratio %v, seeding time %vm, inactive seeding time %vm", ratio, seeding, inactive)
+	case PolicyActionAddTag:
+		return "add tag " + strconv.Quote(a.Tag)
+	case PolicyActionRemoveTag:
+		return "remove tag " + strconv.Quote(a.Tag)
+	case PolicyActionSetCategory:
+		return "set category " + strconv.Quote(a.Category)
+	case PolicyActionSetUploadLimit:
+		return "set upload limit " + strconv.FormatInt(a.UploadLimit, 10) + " B/s"
+	default:
+		return string(a.Type)
+	}
+}
+
+// shareLimits returns the limits of PolicyActionSetShareLimit, ShareLimitGlobal for those
+// not set.
+func (a PolicyAction) shareLimits() (float64, int64, int64) {
+	ratio, seeding, inactive := a.RatioLimit, a.SeedingTimeLimit, a.InactiveSeedingTimeLimit
+	if ratio == 0 {
+		ratio = ShareLimitGlobal
+	}
+	if seeding == 0 {
+		seeding = ShareLimitGlobal
+	}
+	if inactive == 0 {
+		inactive = ShareLimitGlobal
+	}
+
+	return ratio, seeding, inactive
+}
+
+// update applies the tag and category changes of a to t, so later policies see them.
+func (a PolicyAction) update(t *Torrent) {
+	switch a.Type {
+	case PolicyActionAddTag, PolicyActionRemoveTag:
+		tags := splitTags(t.Tags)
+		for _, tag := range splitTags(a.Tag) {
+			tags = slices.DeleteFunc(tags, func(s string) bool { return s == tag })
+			if a.Type == PolicyActionAddTag {
+				tags = append(tags, tag)
+			}
+		}
+		t.Tags = strings.Join(tags, ", ")
+	case PolicyActionSetCategory:
+		t.Category = a.Category
+	}
+}
+
+func (a PolicyAction) apply(ctx context.Context, c *Client, hashes []string) error {
+	switch a.Type {
+	case PolicyActionStop:
+		return c.StopCtx(ctx, hashes)
+	case PolicyActionDelete:
+		return c.DeleteTorrentsCtx(ctx, hashes, a.DeleteFiles)
+	case PolicyActionSetShareLimit:
+		ratio, seeding, inactive := a.shareLimits()
+		return c.SetTorrentShareLimitCtx(ctx, hashes, ratio, seeding, inactive)
+	case PolicyActionAddTag:
+		return c.AddTagsCtx(ctx, hashes, a.Tag)
+	case PolicyActionRemoveTag:
+		return c.RemoveTagsCtx(ctx, hashes, a.Tag)
+	case PolicyActionSetCategory:
+		return c.SetCategoryCtx(ctx, hashes, a.Category)
+	case PolicyActionSetUploadLimit:
+		return c.SetTorrentUploadLimitCtx(ctx, hashes, a.UploadLimit)
+	default:
+		return errors.New("unknown policy action %q", a.Type)
+	}
+}
+
+// Policy applies Actions to the torrents matching Filter, a filter expression such as
+//
+//	tracker =~ "example" && ratio > 2 && seeding_time > 14d && category = "tv"
+type Policy struct {
+	Name    string
+	Filter  string
+	Actions []PolicyAction
+
+	// Limit caps the torrents the policy acts on per Period, or per run if Period is
+	// not set. 0 for no limit. Torrents over the limit are acted on in a later run.
+	Limit  int
+	Period time.Duration
+}
+
+// PolicyEngine applies policies to the torrents of a client. Policies are applied in
+// order; a torrent deleted by one is not seen by later ones, and later ones see the tags
+// and categories set by earlier ones.
+type PolicyEngine struct {
+	client   *Client
+	policies []compiledPolicy
+
+	mu      sync.Mutex
+	history map[string][]time.Time // by policy name, when torrents were acted on
+
+	now func() time.Time
+}
+
+type compiledPolicy struct {
+	Policy
+	filter filterExpr
+}
+
+// NewPolicyEngine compiles the filters of policies, whose names must be unique.
+func NewPolicyEngine(c *Client, policies []Policy) (*PolicyEngine, error) {
+	e := &PolicyEngine{
+		client:  c,
+		history: map[string][]time.Time{},
+		now:     time.Now,
+	}
+
+	names := map[string]struct{}{}
+	for _, p := range policies {
+		if _, ok := names[p.Name]; ok {
+			return nil, errors.New("duplicate policy name %q", p.Name)
+		}
+		names[p.Name] = struct{}{}
+
+		filter, err := parseFilter(p.Filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid filter of policy %q", p.Name)
+		}
+
+		for _, a := range p.Actions {
+			switch a.Type {
+			case PolicyActionStop, PolicyActionDelete, PolicyActionSetShareLimit, PolicyActionAddTag,
+				PolicyActionRemoveTag, PolicyActionSetCategory, PolicyActionSetUploadLimit:
+			default:
+				return nil, errors.New("unknown action %q of policy %q", a.Type, p.Name)
+			}
+		}
+
+		e.policies = append(e.policies, compiledPolicy{Policy: p, filter: filter})
+	}
+
+	return e, nil
+}
+
+type PolicyReport struct {
+	DryRun  bool
+	Results []PolicyResult
+}
+
+type PolicyResult struct {
+	Policy  string
+	Actions []PolicyAction
+
+	// Torrents were acted on, or would have been in a dry run.
+	Torrents []PolicyTorrent
+
+	// Failed torrents matched, but all actions on them failed.
+	Failed []PolicyTorrent
+
+	// RateLimited torrents matched but were over the limit of the policy.
+	RateLimited []PolicyTorrent
+
+	// Errors of the actions that failed.
+	Errors []error
+}
+
+type PolicyTorrent struct {
+	Hash string
+	Name string
+}
+
+// WriteTo writes the report in a human readable form, one line per torrent.
+func (r *PolicyReport) WriteTo(w io.Writer) (int64, error) {
+	prefix := ""
+	if r.DryRun {
+		prefix = "[dry-run] "
+	}
+
+	var n int64
+	write := func(format string, args ...any) error {
+		m, err := fmt.Fprintf(w, prefix+format+"\n", args...)
+		n += int64(m)
+		return err
+	}
+
+	for _, res := range r.Results {
+		actions := make([]string, 0, len(res.Actions))
+		for _, a := range res.Actions {
+			actions = append(actions, a.String())
+		}
+
+		for _, t := range res.Torrents {
+			if err := write("%s: %s: %s %s", res.Policy, strings.Join(actions, ", "), t.Hash, t.Name); err != nil {
+				return n, err
+			}
+		}
+		for _, t := range res.Failed {
+			if err := write("%s: failed %s %s", res.Policy, t.Hash, t.Name); err != nil {
+				return n, err
+			}
+		}
+		for _, t := range res.RateLimited {
+			if err := write("%s: rate limited %s %s", res.Policy, t.Hash, t.Name); err != nil {
+				return n, err
+			}
+		}
+		for _, err := range res.Errors {
+			if err := write("%s: error: %v", res.Policy, err); err != nil {
+				return n, err
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// Run applies the policies once, see RunCtx.
+func (e *PolicyEngine) Run(dryRun bool) (*PolicyReport, error) {
+	return e.RunCtx(context.Background(), dryRun)
+}
+
+// RunCtx gets all torrents and applies each policy to those matching its filter. With
+// dryRun nothing is changed and rate limits are not used up; the report tells what
+// would have been done. A failing action is reported in its result and does not stop
+// other policies; if a delete failed, later policies still see the torrents. Torrents
+// only count against the Limit of a policy if one of its actions succeeded.
+func (e *PolicyEngine) RunCtx(ctx context.Context, dryRun bool) (*PolicyReport, error) {
+	torrents, err := e.client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get torrents")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := e.now()
+	deleted := map[string]struct{}{}
+	report := &PolicyReport{DryRun: dryRun}
+
+	for _, p := range e.policies {
+		res := PolicyResult{Policy: p.Name, Actions: p.Actions}
+		allowed := e.allowance(p, now)
+
+		var (
+			hashes  []string
+			matched []*Torrent
+			acted   []PolicyTorrent
+		)
+		for i := range torrents {
+			t := &torrents[i]
+			if _, ok := deleted[t.Hash]; ok || !p.filter.eval(t, now) {
+				continue
+			}
+
+			pt := PolicyTorrent{Hash: t.Hash, Name: t.Name}
+			if allowed == 0 {
+				res.RateLimited = append(res.RateLimited, pt)
+				continue
+			}
+			allowed--
+
+			acted = append(acted, pt)
+			hashes = append(hashes, t.Hash)
+			matched = append(matched, t)
+		}
+
+		applied := false
+		for _, a := range p.Actions {
+			if len(hashes) == 0 {
+				break
+			}
+
+			if !dryRun {
+				if err := a.apply(ctx, e.client, hashes); err != nil {
+					res.Errors = append(res.Errors, errors.Wrap(err, "could not %v", a))
+					continue
+				}
+				applied = true
+			}
+
+			if a.Type == PolicyActionDelete {
+				for _, h := range hashes {
+					deleted[h] = struct{}{}
+				}
+			}
+
+			for _, t := range matched {
+				a.update(t)
+			}
+		}
+
+		if dryRun || applied {
+			res.Torrents = acted
+		} else {
+			res.Failed = acted
+		}
+
+		// torrents no action succeeded for are not counted against the limit
+		if applied && p.Limit > 0 && p.Period > 0 {
+			for range hashes {
+				e.history[p.Name] = append(e.history[p.Name], now)
+			}
+		}
+
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
+
+// allowance returns how many torrents p may still act on, -1 for any number. e.mu must
+// be held.
+func (e *PolicyEngine) allowance(p compiledPolicy, now time.Time) int {
+	if p.Limit <= 0 {
+		return -1
+	}
+
+	if p.Period <= 0 {
+		return p.Limit
+	}
+
+	// forget what is out of the window
+	history := e.history[p.Name]
+	i := 0
+	for i < len(history) && now.Sub(history[i]) >= p.Period {
+		i++
+	}
+	history = history[i:]
+	e.history[p.Name] = history
+
+	return max(0, p.Limit-len(history))
+}
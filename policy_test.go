@@ -0,0 +1,213 @@
+package qbittorrent
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPolicyTestClient(t *testing.T) (*Client, func() []string) {
+	var calls callRecorder
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[
+				{"hash":"a","name":"A","category":"tv","ratio":3,"seeding_time":2000000,"tracker":"https://tracker.example.org/announce"},
+				{"hash":"b","name":"B","category":"tv","ratio":2.5,"seeding_time":2000000,"tracker":"https://tracker.example.org/announce"},
+				{"hash":"c","name":"C","category":"tv","ratio":0.5,"seeding_time":2000000,"tracker":"https://tracker.example.org/announce"},
+				{"hash":"d","name":"D","category":"movies","ratio":5,"tracker":"https://other.example/announce"}
+			]`))
+		},
+		"torrents/stop":           calls.handler("stop"),
+		"torrents/delete":         calls.handler("delete"),
+		"torrents/addTags":        calls.handler("addTags", "tags"),
+		"torrents/setShareLimits": calls.handler("setShareLimits", "ratioLimit", "seedingTimeLimit", "inactiveSeedingTimeLimit"),
+	})
+
+	return client, calls.get
+}
+
+func TestPolicyEngine_RunCtx(t *testing.T) {
+	client, calls := newPolicyTestClient(t)
+
+	e, err := NewPolicyEngine(client, []Policy{
+		{
+			Name:    "tv",
+			Filter:  `tracker =~ "example.org" && ratio > 2 && seeding_time > 14d && category = "tv"`,
+			Actions: []PolicyAction{{Type: PolicyActionAddTag, Tag: "done"}, {Type: PolicyActionDelete}},
+		},
+		{
+			Name:    "all",
+			Filter:  `ratio >= 0`,
+			Actions: []PolicyAction{{Type: PolicyActionStop}},
+		},
+	})
+	require.NoError(t, err)
+
+	report, err := e.RunCtx(context.Background(), true)
+	require.NoError(t, err)
+	assert.Empty(t, calls())
+
+	var buf bytes.Buffer
+	_, err = report.WriteTo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, `[dry-run] tv: add tag "done", delete: a A
+[dry-run] tv: add tag "done", delete: b B
+[dry-run] all: stop: c C
+[dry-run] all: stop: d D
+`, buf.String())
+
+	report, err = e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Equal(t, []string{"addTags a|b done", "delete a|b", "stop c|d"}, calls())
+}
+
+func TestPolicyEngine_SetShareLimit(t *testing.T) {
+	client, calls := newPolicyTestClient(t)
+
+	e, err := NewPolicyEngine(client, []Policy{
+		{
+			Name:   "ratio",
+			Filter: `category = "movies"`,
+			// the seeding time limits are not set and left to the global ones
+			Actions: []PolicyAction{{Type: PolicyActionSetShareLimit, RatioLimit: 2}},
+		},
+		{
+			Name:    "unlimited",
+			Filter:  `ratio < 1`,
+			Actions: []PolicyAction{{Type: PolicyActionSetShareLimit, RatioLimit: ShareLimitNone, SeedingTimeLimit: 60}},
+		},
+	})
+	require.NoError(t, err)
+
+	report, err := e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"setShareLimits d 2.00 -2 -2", "setShareLimits c -1.00 60 -2"}, calls())
+	assert.Equal(t, "set share limit ratio 2, seeding time -2m, inactive seeding time -2m", report.Results[0].Actions[0].String())
+}
+
+func TestPolicyEngine_Chain(t *testing.T) {
+	client, calls := newPolicyTestClient(t)
+
+	// later policies see the tags added by earlier ones within the same run
+	e, err := NewPolicyEngine(client, []Policy{
+		{Name: "mark", Filter: `category = "movies"`, Actions: []PolicyAction{{Type: PolicyActionAddTag, Tag: "done"}}},
+		{Name: "stop", Filter: `tag = "done"`, Actions: []PolicyAction{{Type: PolicyActionStop}}},
+	})
+	require.NoError(t, err)
+
+	report, err := e.RunCtx(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, []PolicyTorrent{{Hash: "d", Name: "D"}}, report.Results[1].Torrents)
+
+	_, err = e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"addTags d done", "stop d"}, calls())
+}
+
+func TestPolicyEngine_Limit(t *testing.T) {
+	client, calls := newPolicyTestClient(t)
+
+	e, err := NewPolicyEngine(client, []Policy{
+		{
+			Name:    "stop",
+			Filter:  `category = "tv"`,
+			Actions: []PolicyAction{{Type: PolicyActionStop}},
+			Limit:   2,
+			Period:  time.Hour,
+		},
+	})
+	require.NoError(t, err)
+
+	now := time.Unix(1_700_000_000, 0)
+	e.now = func() time.Time { return now }
+
+	report, err := e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, []PolicyTorrent{{Hash: "a", Name: "A"}, {Hash: "b", Name: "B"}}, report.Results[0].Torrents)
+	assert.Equal(t, []PolicyTorrent{{Hash: "c", Name: "C"}}, report.Results[0].RateLimited)
+
+	// the limit is used up within the period
+	now = now.Add(30 * time.Minute)
+	report, err = e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.Empty(t, report.Results[0].Torrents)
+	assert.Len(t, report.Results[0].RateLimited, 3)
+
+	now = now.Add(30 * time.Minute)
+	report, err = e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	assert.Len(t, report.Results[0].Torrents, 2)
+
+	assert.Equal(t, []string{"stop a|b", "stop a|b"}, calls())
+}
+
+func TestPolicyEngine_Errors(t *testing.T) {
+	client, _ := newPolicyTestClient(t)
+
+	_, err := NewPolicyEngine(client, []Policy{{Name: "a", Filter: "ratio > 1"}, {Name: "a", Filter: "ratio > 2"}})
+	assert.ErrorContains(t, err, `duplicate policy name "a"`)
+
+	_, err = NewPolicyEngine(client, []Policy{{Name: "a", Filter: "ratio >"}})
+	assert.ErrorContains(t, err, `invalid filter of policy "a"`)
+
+	_, err = NewPolicyEngine(client, []Policy{{Name: "a", Filter: "ratio > 1", Actions: []PolicyAction{{Type: "pause"}}}})
+	assert.ErrorContains(t, err, `unknown action "pause" of policy "a"`)
+
+	// a failing action is reported
+	e, err := NewPolicyEngine(client, []Policy{
+		{Name: "category", Filter: "ratio > 1", Actions: []PolicyAction{{Type: PolicyActionSetCategory, Category: "x"}}},
+	})
+	require.NoError(t, err)
+
+	report, err := e.RunCtx(context.Background(), false)
+	require.NoError(t, err)
+	require.Len(t, report.Results[0].Errors, 1)
+	assert.ErrorContains(t, report.Results[0].Errors[0], `could not set category "x"`)
+}
+
+func TestPolicyEngine_FailedActions(t *testing.T) {
+	var stopped int
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"hash":"a","name":"A","ratio":3}]`))
+		},
+		"torrents/delete": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusConflict)
+		},
+		"torrents/stop": func(w http.ResponseWriter, r *http.Request) {
+			stopped++
+		},
+	})
+
+	e, err := NewPolicyEngine(client, []Policy{
+		{Name: "delete", Filter: "ratio > 1", Actions: []PolicyAction{{Type: PolicyActionDelete}}, Limit: 1, Period: time.Hour},
+		{Name: "stop", Filter: "ratio > 1", Actions: []PolicyAction{{Type: PolicyActionStop}}},
+	})
+	require.NoError(t, err)
+
+	for range 2 {
+		report, err := e.RunCtx(context.Background(), false)
+		require.NoError(t, err)
+
+		// the failed delete does not use up the limit and the torrent is still there
+		assert.Empty(t, report.Results[0].Torrents)
+		assert.Equal(t, []PolicyTorrent{{Hash: "a", Name: "A"}}, report.Results[0].Failed)
+		assert.Empty(t, report.Results[0].RateLimited)
+		assert.Len(t, report.Results[0].Errors, 1)
+		assert.Equal(t, []PolicyTorrent{{Hash: "a", Name: "A"}}, report.Results[1].Torrents)
+
+		var buf bytes.Buffer
+		_, err = report.WriteTo(&buf)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), "delete: failed a A\n")
+	}
+
+	assert.Equal(t, 2, stopped)
+}
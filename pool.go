@@ -0,0 +1,391 @@
+package qbittorrent
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// Pool manages clients of several instances by name. Reads fan out to all instances
+// concurrently; adds are routed to one instance by a RoutingStrategy.
+type Pool struct {
+	mu       sync.RWMutex
+	clients  map[string]*Client
+	strategy RoutingStrategy
+}
+
+// PoolInstance is a client of a Pool and the name it was added under.
+type PoolInstance struct {
+	Name   string
+	Client *Client
+}
+
+// NewPool returns an empty pool routing adds with strategy, RouteByFreeSpace if nil.
+func NewPool(strategy RoutingStrategy) *Pool {
+	if strategy == nil {
+		strategy = RouteByFreeSpace()
+	}
+
+	return &Pool{
+		clients:  map[string]*Client{},
+		strategy: strategy,
+	}
+}
+
+// Add adds a client under name, replacing any client of that name.
+func (p *Pool) Add(name string, c *Client) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.clients[name] = c
+}
+
+// Remove removes the client of name, if any.
+func (p *Pool) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.clients, name)
+}
+
+// Get returns the client of name.
+func (p *Pool) Get(name string) (*Client, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	c, ok := p.clients[name]
+	return c, ok
+}
+
+// Instances returns the clients of the pool sorted by name.
+func (p *Pool) Instances() []PoolInstance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	instances := make([]PoolInstance, 0, len(p.clients))
+	for name, c := range p.clients {
+		instances = append(instances, PoolInstance{Name: name, Client: c})
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Name < instances[j].Name
+	})
+
+	return instances
+}
+
+// PoolError holds the errors of the instances a pool call failed on. Results of the other
+// instances are still returned with it.
+type PoolError struct {
+	// Errors is keyed by instance name.
+	Errors map[string]error
+}
+
+func (e *PoolError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, name+": "+e.Errors[name].Error())
+	}
+
+	return "pool: " + strings.Join(msgs, "; ")
+}
+
+func (e *PoolError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// fanOut calls fn for each instance concurrently and returns the results by instance name.
+// The error is a *PoolError if fn failed on any instance.
+func fanOut[T any](ctx context.Context, instances []PoolInstance, fn func(context.Context, *Client) (T, error)) (map[string]T, error) {
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = make(map[string]T, len(instances))
+		errs    = map[string]error{}
+	)
+
+	for _, instance := range instances {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			res, err := fn(ctx, instance.Client)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				errs[instance.Name] = err
+				return
+			}
+
+			results[instance.Name] = res
+		}()
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return results, &PoolError{Errors: errs}
+	}
+
+	return results, nil
+}
+
+// InstanceTorrent is a torrent and the name of the instance it is on.
+type InstanceTorrent struct {
+	Instance string
+	Torrent
+}
+
+func (p *Pool) GetTorrents(o TorrentFilterOptions) ([]InstanceTorrent, error) {
+	return p.GetTorrentsCtx(context.Background(), o)
+}
+
+// GetTorrentsCtx gets the torrents matching o of all instances, ordered by instance name.
+// Torrents of the instances that answered are returned even if others failed, with a
+// *PoolError.
+func (p *Pool) GetTorrentsCtx(ctx context.Context, o TorrentFilterOptions) ([]InstanceTorrent, error) {
+	instances := p.Instances()
+
+	results, err := fanOut(ctx, instances, func(ctx context.Context, c *Client) ([]Torrent, error) {
+		return c.GetTorrentsCtx(ctx, o)
+	})
+
+	var torrents []InstanceTorrent
+	for _, instance := range instances {
+		for _, t := range results[instance.Name] {
+			torrents = append(torrents, InstanceTorrent{Instance: instance.Name, Torrent: t})
+		}
+	}
+
+	return torrents, err
+}
+
+// InstanceTransferInfo is the transfer info of the named instance.
+type InstanceTransferInfo struct {
+	Instance string
+	TransferInfo
+}
+
+func (p *Pool) GetTransferInfo() ([]InstanceTransferInfo, error) {
+	return p.GetTransferInfoCtx(context.Background())
+}
+
+// GetTransferInfoCtx gets the transfer info of all instances, ordered by instance name.
+// Like GetTorrentsCtx it returns the instances that answered along with a *PoolError.
+func (p *Pool) GetTransferInfoCtx(ctx context.Context) ([]InstanceTransferInfo, error) {
+	instances := p.Instances()
+
+	results, err := fanOut(ctx, instances, func(ctx context.Context, c *Client) (*TransferInfo, error) {
+		return c.GetTransferInfoCtx(ctx)
+	})
+
+	var infos []InstanceTransferInfo
+	for _, instance := range instances {
+		if info, ok := results[instance.Name]; ok {
+			infos = append(infos, InstanceTransferInfo{Instance: instance.Name, TransferInfo: *info})
+		}
+	}
+
+	return infos, err
+}
+
+// RoutingStrategy picks the instance a torrent is added to.
+type RoutingStrategy interface {
+	// Route returns the name of one of instances for a torrent added with options.
+	// instances is never empty.
+	Route(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error)
+}
+
+// RoutingStrategyFunc adapts a function to a RoutingStrategy.
+type RoutingStrategyFunc func(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error)
+
+func (f RoutingStrategyFunc) Route(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error) {
+	return f(ctx, instances, options)
+}
+
+// RouteByFreeSpace routes to the instance with the most free space on disk, as reported by
+// GetFreeSpaceOnDiskCtx. Instances that fail to report it are skipped.
+func RouteByFreeSpace() RoutingStrategy {
+	return RoutingStrategyFunc(func(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error) {
+		free, err := fanOut(ctx, instances, func(ctx context.Context, c *Client) (int64, error) {
+			return c.GetFreeSpaceOnDiskCtx(ctx)
+		})
+
+		return best(instances, free, err, func(a, b int64) bool { return a > b })
+	})
+}
+
+// RouteByActiveDownloads routes to the instance with the fewest active downloads, see
+// GetTorrentsActiveDownloadsCtx. Instances that fail to report them are skipped.
+func RouteByActiveDownloads() RoutingStrategy {
+	return RoutingStrategyFunc(func(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error) {
+		active, err := fanOut(ctx, instances, func(ctx context.Context, c *Client) (int, error) {
+			torrents, err := c.GetTorrentsActiveDownloadsCtx(ctx)
+			return len(torrents), err
+		})
+
+		return best(instances, active, err, func(a, b int) bool { return a < b })
+	})
+}
+
+// best returns the instance whose value is better than all others, the first by name on a
+// tie. err is returned if no instance has a value.
+func best[T any](instances []PoolInstance, values map[string]T, err error, better func(a, b T) bool) (string, error) {
+	var (
+		name  string
+		value T
+	)
+
+	for _, instance := range instances {
+		v, ok := values[instance.Name]
+		if !ok {
+			continue
+		}
+
+		if name == "" || better(v, value) {
+			name, value = instance.Name, v
+		}
+	}
+
+	if name == "" {
+		return "", errors.Wrap(err, "no instance to route to")
+	}
+
+	return name, nil
+}
+
+// RouteByCategory routes torrents by their category option. A category in affinity goes to
+// the instance it maps to; any other category goes to the instances that already have it.
+// fallback picks among those instances, or among all instances if none has the category
+// or no category is set. fallback defaults to RouteByFreeSpace.
+func RouteByCategory(affinity map[string]string, fallback RoutingStrategy) RoutingStrategy {
+	if fallback == nil {
+		fallback = RouteByFreeSpace()
+	}
+
+	return RoutingStrategyFunc(func(ctx context.Context, instances []PoolInstance, options map[string]string) (string, error) {
+		category := options["category"]
+		if category == "" {
+			return fallback.Route(ctx, instances, options)
+		}
+
+		if name, ok := affinity[category]; ok {
+			for _, instance := range instances {
+				if instance.Name == name {
+					return name, nil
+				}
+			}
+
+			return "", errors.New("instance %v of category %v is not available", name, category)
+		}
+
+		// failing instances just do not have the category
+		categories, _ := fanOut(ctx, instances, func(ctx context.Context, c *Client) (map[string]Category, error) {
+			return c.GetCategoriesCtx(ctx)
+		})
+
+		var candidates []PoolInstance
+		for _, instance := range instances {
+			if _, ok := categories[instance.Name][category]; ok {
+				candidates = append(candidates, instance)
+			}
+		}
+
+		if len(candidates) == 0 {
+			candidates = instances
+		}
+
+		return fallback.Route(ctx, candidates, options)
+	})
+}
+
+// Route returns the instance a torrent added with options goes to. Instances that are
+// not Healthy are not considered: those whose circuit breaker is open, or, without
+// Config.BreakerThreshold set, whose last request failed.
+func (p *Pool) Route(ctx context.Context, options map[string]string) (PoolInstance, error) {
+	var healthy []PoolInstance
+	for _, instance := range p.Instances() {
+		if instance.Client.Health().Healthy() {
+			healthy = append(healthy, instance)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return PoolInstance{}, errors.Wrap(ErrInstanceUnavailable, "no healthy instance in pool")
+	}
+
+	name, err := p.strategy.Route(ctx, healthy, options)
+	if err != nil {
+		return PoolInstance{}, errors.Wrap(err, "could not route torrent")
+	}
+
+	for _, instance := range healthy {
+		if instance.Name == name {
+			return instance, nil
+		}
+	}
+
+	return PoolInstance{}, errors.New("routed to unknown instance %v", name)
+}
+
+func (p *Pool) AddTorrentFromMemory(buf []byte, options map[string]string) (string, error) {
+	return p.AddTorrentFromMemoryCtx(context.Background(), buf, options)
+}
+
+// AddTorrentFromMemoryCtx adds a torrent to the instance picked by Route and returns its
+// name.
+func (p *Pool) AddTorrentFromMemoryCtx(ctx context.Context, buf []byte, options map[string]string) (string, error) {
+	instance, err := p.Route(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.Name, instance.Client.AddTorrentFromMemoryCtx(ctx, buf, options)
+}
+
+func (p *Pool) AddTorrentFromFile(filePath string, options map[string]string) (string, error) {
+	return p.AddTorrentFromFileCtx(context.Background(), filePath, options)
+}
+
+// AddTorrentFromFileCtx adds a torrent to the instance picked by Route and returns its
+// name.
+func (p *Pool) AddTorrentFromFileCtx(ctx context.Context, filePath string, options map[string]string) (string, error) {
+	instance, err := p.Route(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.Name, instance.Client.AddTorrentFromFileCtx(ctx, filePath, options)
+}
+
+func (p *Pool) AddTorrentFromUrl(url string, options map[string]string) (string, error) {
+	return p.AddTorrentFromUrlCtx(context.Background(), url, options)
+}
+
+// AddTorrentFromUrlCtx adds a torrent to the instance picked by Route and returns its
+// name.
+func (p *Pool) AddTorrentFromUrlCtx(ctx context.Context, url string, options map[string]string) (string, error) {
+	instance, err := p.Route(ctx, options)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.Name, instance.Client.AddTorrentFromUrlCtx(ctx, url, options)
+}
@@ -0,0 +1,168 @@
+package qbittorrent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type poolTestInstance struct {
+	name       string
+	torrents   string
+	freeSpace  int64
+	categories string
+	fail       bool
+}
+
+func newTestPool(t *testing.T, strategy RoutingStrategy, instances ...poolTestInstance) (*Pool, map[string][]string) {
+	t.Helper()
+
+	added := map[string][]string{}
+	pool := NewPool(strategy)
+
+	for _, inst := range instances {
+		status := func(w http.ResponseWriter) bool {
+			if inst.fail {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return inst.fail
+		}
+
+		client := newTestClient(t, "2.9.3", map[string]http.HandlerFunc{
+			"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+				if status(w) {
+					return
+				}
+				_, _ = w.Write([]byte(inst.torrents))
+			},
+			"transfer/info": func(w http.ResponseWriter, r *http.Request) {
+				if status(w) {
+					return
+				}
+				_, _ = fmt.Fprintf(w, `{"dl_info_speed":%d,"connection_status":"connected"}`, inst.freeSpace)
+			},
+			"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+				if status(w) {
+					return
+				}
+				_, _ = fmt.Fprintf(w, `{"rid":1,"full_update":true,"server_state":{"free_space_on_disk":%d}}`, inst.freeSpace)
+			},
+			"torrents/categories": func(w http.ResponseWriter, r *http.Request) {
+				if status(w) {
+					return
+				}
+				_, _ = w.Write([]byte(inst.categories))
+			},
+			"torrents/add": func(w http.ResponseWriter, r *http.Request) {
+				added[inst.name] = append(added[inst.name], r.FormValue("urls"))
+			},
+		})
+
+		pool.Add(inst.name, client)
+	}
+
+	return pool, added
+}
+
+func TestPool_GetTorrentsCtx(t *testing.T) {
+	pool, _ := newTestPool(t, nil,
+		poolTestInstance{name: "b", torrents: `[{"hash":"b1"}]`},
+		poolTestInstance{name: "a", torrents: `[{"hash":"a1"},{"hash":"a2"}]`},
+		poolTestInstance{name: "c", fail: true},
+	)
+
+	torrents, err := pool.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+
+	var poolErr *PoolError
+	require.ErrorAs(t, err, &poolErr)
+	assert.Len(t, poolErr.Errors, 1)
+	assert.Contains(t, poolErr.Errors, "c")
+
+	var got []string
+	for _, torrent := range torrents {
+		got = append(got, torrent.Instance+"/"+torrent.Hash)
+	}
+	assert.Equal(t, []string{"a/a1", "a/a2", "b/b1"}, got)
+}
+
+func TestPool_GetTransferInfoCtx(t *testing.T) {
+	pool, _ := newTestPool(t, nil,
+		poolTestInstance{name: "a", freeSpace: 1},
+		poolTestInstance{name: "b", freeSpace: 2},
+	)
+
+	infos, err := pool.GetTransferInfoCtx(context.Background())
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, "a", infos[0].Instance)
+	assert.EqualValues(t, 1, infos[0].DlInfoSpeed)
+	assert.Equal(t, "b", infos[1].Instance)
+	assert.EqualValues(t, 2, infos[1].DlInfoSpeed)
+}
+
+func TestPool_Route(t *testing.T) {
+	instances := []poolTestInstance{
+		{name: "a", freeSpace: 100, torrents: `[{"state":"downloading"},{"state":"stalledDL"}]`, categories: `{"movies":{"name":"movies"}}`},
+		{name: "b", freeSpace: 300, torrents: `[{"state":"downloading"},{"state":"downloading"},{"state":"pausedDL"}]`, categories: `{}`},
+		{name: "c", freeSpace: 200, torrents: `[{"state":"pausedDL"}]`, categories: `{"movies":{"name":"movies"}}`},
+		{name: "d", fail: true},
+	}
+
+	tests := []struct {
+		name     string
+		strategy RoutingStrategy
+		options  map[string]string
+		want     string
+	}{
+		{name: "free_space", strategy: RouteByFreeSpace(), want: "b"},
+		{name: "active_downloads", strategy: RouteByActiveDownloads(), want: "c"},
+		{name: "category_affinity", strategy: RouteByCategory(map[string]string{"tv": "a"}, nil), options: map[string]string{"category": "tv"}, want: "a"},
+		{name: "category_existing", strategy: RouteByCategory(nil, nil), options: map[string]string{"category": "movies"}, want: "c"},
+		{name: "category_unknown", strategy: RouteByCategory(nil, nil), options: map[string]string{"category": "music"}, want: "b"},
+		{name: "category_none", strategy: RouteByCategory(nil, RouteByActiveDownloads()), want: "c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool, added := newTestPool(t, tt.strategy, instances...)
+
+			options := map[string]string{}
+			for k, v := range tt.options {
+				options[k] = v
+			}
+
+			name, err := pool.AddTorrentFromUrlCtx(context.Background(), "http://example.com/a.torrent", options)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, name)
+			assert.Equal(t, map[string][]string{tt.want: {"http://example.com/a.torrent"}}, added)
+		})
+	}
+}
+
+func TestPool_Route_SkipsUnhealthy(t *testing.T) {
+	pool, _ := newTestPool(t, RouteByFreeSpace(),
+		poolTestInstance{name: "a", freeSpace: 100},
+		poolTestInstance{name: "b", freeSpace: 300},
+	)
+
+	a, _ := pool.Get("a")
+	b, _ := pool.Get("b")
+	a.breaker = newBreaker(1, time.Minute)
+	b.breaker = newBreaker(1, time.Minute)
+
+	b.breaker.health.State = BreakerOpen
+
+	instance, err := pool.Route(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Equal(t, "a", instance.Name)
+
+	a.breaker.health.State = BreakerOpen
+
+	_, err = pool.Route(context.Background(), nil)
+	assert.ErrorIs(t, err, ErrInstanceUnavailable)
+}
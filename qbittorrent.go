@@ -2,8 +2,8 @@ package qbittorrent
 
 import (
 	"crypto/tls"
-	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"net/http/cookiejar"
@@ -23,9 +23,13 @@ type Client struct {
 	http    *http.Client
 	timeout time.Duration
 
-	log *log.Logger
+	log *slog.Logger
 
 	version *semver.Version
+
+	stats   clientStats
+	limiter *limiter
+	breaker *breaker
 }
 
 type Config struct {
@@ -43,19 +47,50 @@ type Config struct {
 	BasicPass string
 
 	Timeout int
-	Log     *log.Logger
+
+	// Logger receives structured logs. Passwords, session ids and tracker passkeys are
+	// redacted.
+	Logger *slog.Logger
+
+	// Log is used when Logger is not set.
+	//
+	// Deprecated: use Logger.
+	Log *log.Logger
+
+	// Middleware wraps the HTTP transport, the first one outermost.
+	Middleware []Middleware
+
+	// Hooks are called around every request and attempt, see RequestHook.
+	Hooks []RequestHook
+
+	// RateLimit caps the requests sent per second, 0 for no limit. Retries count too.
+	RateLimit float64
+
+	// RateBurst is how many requests may be sent at once before RateLimit applies.
+	// Defaults to 1.
+	RateBurst int
+
+	// MaxConcurrentRequests caps the requests in flight, 0 for no limit. A request is in
+	// flight until its response body is closed.
+	MaxConcurrentRequests int
+
+	// BreakerThreshold is the number of consecutive transport failures after which
+	// requests fail fast with ErrInstanceUnavailable, see Client.Health. 0 disables the
+	// breaker.
+	BreakerThreshold int
+
+	// BreakerCooldown is how long requests fail fast before app/version is probed to see
+	// whether the instance is back. Defaults to DefaultBreakerCooldown.
+	BreakerCooldown time.Duration
 }
 
 func NewClient(cfg Config) *Client {
 	c := &Client{
 		cfg:     cfg,
-		log:     log.New(io.Discard, "", log.LstdFlags),
+		log:     newLogger(cfg),
 		timeout: DefaultTimeout,
-	}
-
-	// override logger if we pass one
-	if cfg.Log != nil {
-		c.log = cfg.Log
+		limiter: newLimiter(cfg.RateLimit, cfg.RateBurst, cfg.MaxConcurrentRequests),
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
 	}
 
 	if cfg.Timeout > 0 {
@@ -66,7 +101,7 @@ func NewClient(cfg Config) *Client {
 	jarOptions := &cookiejar.Options{PublicSuffixList: publicsuffix.List}
 	jar, err := cookiejar.New(jarOptions)
 	if err != nil {
-		c.log.Println("new client cookie error")
+		c.log.Error("new client cookie error", "error", err)
 	}
 
 	customTransport := &http.Transport{
@@ -91,7 +126,7 @@ func NewClient(cfg Config) *Client {
 	c.http = &http.Client{
 		Jar:       jar,
 		Timeout:   c.timeout,
-		Transport: customTransport,
+		Transport: c.wrapTransport(customTransport),
 	}
 
 	return c
@@ -100,6 +135,7 @@ func NewClient(cfg Config) *Client {
 // WithHTTPClient allows you to a provide a custom [http.Client].
 func (c *Client) WithHTTPClient(client *http.Client) *Client {
 	client.Jar = c.http.Jar
+	client.Transport = c.wrapTransport(client.Transport)
 	c.http = client
 	return c
 }
@@ -0,0 +1,252 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+var (
+	// DefaultSampleInterval is how often ServerStateSampler samples by default.
+	DefaultSampleInterval = 10 * time.Second
+
+	// DefaultSampleSize keeps 24 hours of samples at DefaultSampleInterval.
+	DefaultSampleSize = 8640
+)
+
+type ServerStateSample struct {
+	Time        time.Time
+	ServerState ServerState
+}
+
+type SamplerOptions struct {
+	// Interval between samples. Defaults to DefaultSampleInterval.
+	Interval time.Duration
+
+	// Size is the number of samples kept, older samples are dropped. Defaults to
+	// DefaultSampleSize.
+	Size int
+
+	// Cache, if set, is sampled instead of the server. It must be kept in sync elsewhere,
+	// e.g. by SyncCache.Run.
+	Cache *SyncCache
+}
+
+// TransferStats summarizes the samples of a time window.
+type TransferStats struct {
+	From    time.Time
+	To      time.Time
+	Samples int
+
+	// average and highest sampled speeds, in bytes per second
+	AvgDlSpeed  float64
+	AvgUpSpeed  float64
+	PeakDlSpeed int64
+	PeakUpSpeed int64
+
+	// transferred during the window according to the session counters, which restart
+	// with qBittorrent; restarts within the window are accounted for
+	Downloaded int64
+	Uploaded   int64
+
+	// transferred during the window according to the all-time counters
+	AlltimeDownloaded int64
+	AlltimeUploaded   int64
+}
+
+// ServerStateSampler records the server state at an interval into a ring buffer and
+// summarizes it, e.g. for throughput graphs.
+//
+// Without SamplerOptions.Cache the sampler polls sync/maindata itself. qBittorrent only
+// keeps the last rid per WebUI session, so on a client shared with a SyncCache or Watcher
+// every poll of either is answered with a full update, all torrents included. Use the
+// cache, or feed samples through Add, on such clients.
+type ServerStateSampler struct {
+	client *Client
+	opts   SamplerOptions
+
+	mu      sync.RWMutex
+	samples []ServerStateSample
+	next    int
+	full    bool
+
+	// sync/maindata state, only used by Sample
+	rid   int64
+	state ServerState
+}
+
+func NewServerStateSampler(c *Client, opts SamplerOptions) *ServerStateSampler {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSampleInterval
+	}
+
+	if opts.Size <= 0 {
+		opts.Size = DefaultSampleSize
+	}
+
+	return &ServerStateSampler{
+		client:  c,
+		opts:    opts,
+		samples: make([]ServerStateSample, opts.Size),
+	}
+}
+
+// Run samples until ctx is done or logging in fails. Failed samples are logged and
+// retried with a growing delay, up to MaxPollBackoff, leaving a gap in the samples.
+func (s *ServerStateSampler) Run(ctx context.Context) error {
+	interval := func() time.Duration { return s.opts.Interval }
+
+	return s.client.pollLoop(ctx, "server state sample", interval, s.Sample)
+}
+
+// Sample requests the server state once and records it, or takes it from
+// SamplerOptions.Cache once the cache synced. It must not be called concurrently, or
+// while Run is running.
+func (s *ServerStateSampler) Sample(ctx context.Context) error {
+	if s.opts.Cache != nil {
+		if state, ok := s.opts.Cache.syncedServerState(); ok {
+			s.Add(time.Now(), state)
+		}
+
+		return nil
+	}
+
+	delta, err := s.client.syncMainDataDeltaCtx(ctx, s.rid)
+	if err != nil {
+		return errors.Wrap(err, "could not sync main data")
+	}
+
+	if delta.FullUpdate {
+		s.state = ServerState{}
+	}
+
+	if len(delta.ServerState) > 0 {
+		if err := json.Unmarshal(delta.ServerState, &s.state); err != nil {
+			return errors.Wrap(err, "could not patch server state")
+		}
+	}
+
+	s.rid = delta.Rid
+	s.Add(time.Now(), s.state)
+
+	return nil
+}
+
+// Add records a sample taken elsewhere, e.g. from a SyncCache or Watcher. Samples are
+// expected in chronological order.
+func (s *ServerStateSampler) Add(t time.Time, state ServerState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples[s.next] = ServerStateSample{Time: t, ServerState: state}
+	s.next = (s.next + 1) % len(s.samples)
+	if s.next == 0 {
+		s.full = true
+	}
+}
+
+// Samples returns the samples taken since the given time, oldest first. A zero since
+// returns all samples.
+func (s *ServerStateSampler) Samples(since time.Time) []ServerStateSample {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.since(since)
+}
+
+// Latest returns the most recent sample.
+func (s *ServerStateSampler) Latest() (ServerStateSample, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.latest()
+}
+
+// Stats summarizes the samples of the last window, or all samples if window is 0.
+func (s *ServerStateSampler) Stats(window time.Duration) TransferStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var since time.Time
+	if window > 0 {
+		if latest, ok := s.latest(); ok {
+			since = latest.Time.Add(-window)
+		}
+	}
+
+	return transferStats(s.since(since))
+}
+
+func (s *ServerStateSampler) latest() (ServerStateSample, bool) {
+	if !s.full && s.next == 0 {
+		return ServerStateSample{}, false
+	}
+
+	return s.samples[(s.next-1+len(s.samples))%len(s.samples)], true
+}
+
+func (s *ServerStateSampler) since(since time.Time) []ServerStateSample {
+	var ordered []ServerStateSample
+	if s.full {
+		ordered = append(ordered, s.samples[s.next:]...)
+	}
+	ordered = append(ordered, s.samples[:s.next]...)
+
+	for i, sample := range ordered {
+		if !sample.Time.Before(since) {
+			return ordered[i:]
+		}
+	}
+
+	return nil
+}
+
+func transferStats(samples []ServerStateSample) TransferStats {
+	var stats TransferStats
+	if len(samples) == 0 {
+		return stats
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	stats.From = first.Time
+	stats.To = last.Time
+	stats.Samples = len(samples)
+
+	var dlSum, upSum int64
+	for i, sample := range samples {
+		state := sample.ServerState
+
+		dlSum += state.DlInfoSpeed
+		upSum += state.UpInfoSpeed
+		stats.PeakDlSpeed = max(stats.PeakDlSpeed, state.DlInfoSpeed)
+		stats.PeakUpSpeed = max(stats.PeakUpSpeed, state.UpInfoSpeed)
+
+		if i == 0 {
+			continue
+		}
+
+		prev := samples[i-1].ServerState
+		stats.Downloaded += counterDelta(prev.DlInfoData, state.DlInfoData)
+		stats.Uploaded += counterDelta(prev.UpInfoData, state.UpInfoData)
+	}
+
+	stats.AvgDlSpeed = float64(dlSum) / float64(len(samples))
+	stats.AvgUpSpeed = float64(upSum) / float64(len(samples))
+	stats.AlltimeDownloaded = max(0, last.ServerState.AlltimeDl-first.ServerState.AlltimeDl)
+	stats.AlltimeUploaded = max(0, last.ServerState.AlltimeUl-first.ServerState.AlltimeUl)
+
+	return stats
+}
+
+// counterDelta is the increase of a session counter, which restarts from zero when
+// qBittorrent does.
+func counterDelta(prev, cur int64) int64 {
+	if cur < prev {
+		return cur
+	}
+
+	return cur - prev
+}
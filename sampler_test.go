@@ -0,0 +1,152 @@
+package qbittorrent
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerState_UnmarshalJSON(t *testing.T) {
+	var state ServerState
+	assert.NoError(t, json.Unmarshal([]byte(`{"global_ratio":"1.52","read_cache_hits":"12.5","read_cache_overload":"0","write_cache_overload":3,"dl_info_speed":10}`), &state))
+	assert.Equal(t, 1.52, state.GlobalRatio)
+	assert.Equal(t, 12.5, state.ReadCacheHits)
+	assert.Equal(t, float64(0), state.ReadCacheOverload)
+	assert.Equal(t, float64(3), state.WriteCacheOverload)
+	assert.Equal(t, int64(10), state.DlInfoSpeed)
+
+	// a partial update keeps the other fields, "-" means there is no ratio yet
+	assert.NoError(t, json.Unmarshal([]byte(`{"global_ratio":"-"}`), &state))
+	assert.Equal(t, float64(0), state.GlobalRatio)
+	assert.Equal(t, 12.5, state.ReadCacheHits)
+	assert.Equal(t, int64(10), state.DlInfoSpeed)
+}
+
+func TestServerStateSampler_Stats(t *testing.T) {
+	sampler := NewServerStateSampler(nil, SamplerOptions{Size: 4})
+	start := time.Unix(1700000000, 0)
+
+	states := []ServerState{
+		{DlInfoSpeed: 100, UpInfoSpeed: 10, DlInfoData: 1000, UpInfoData: 100, AlltimeDl: 5000, AlltimeUl: 500},
+		{DlInfoSpeed: 300, UpInfoSpeed: 30, DlInfoData: 2000, UpInfoData: 200, AlltimeDl: 6000, AlltimeUl: 600},
+		{DlInfoSpeed: 200, UpInfoSpeed: 20, DlInfoData: 3000, UpInfoData: 300, AlltimeDl: 7000, AlltimeUl: 700},
+		// qBittorrent restarted, the session counters start over
+		{DlInfoSpeed: 0, UpInfoSpeed: 0, DlInfoData: 500, UpInfoData: 50, AlltimeDl: 7500, AlltimeUl: 750},
+		{DlInfoSpeed: 400, UpInfoSpeed: 40, DlInfoData: 1500, UpInfoData: 150, AlltimeDl: 8500, AlltimeUl: 850},
+	}
+	for i, state := range states {
+		sampler.Add(start.Add(time.Duration(i)*time.Minute), state)
+	}
+
+	// the ring only holds the last 4
+	samples := sampler.Samples(time.Time{})
+	assert.Len(t, samples, 4)
+	assert.Equal(t, start.Add(time.Minute), samples[0].Time)
+
+	latest, ok := sampler.Latest()
+	assert.True(t, ok)
+	assert.Equal(t, int64(400), latest.ServerState.DlInfoSpeed)
+
+	stats := sampler.Stats(0)
+	assert.Equal(t, 4, stats.Samples)
+	assert.Equal(t, float64(225), stats.AvgDlSpeed)
+	assert.Equal(t, int64(400), stats.PeakDlSpeed)
+	assert.Equal(t, int64(40), stats.PeakUpSpeed)
+	assert.Equal(t, int64(1000+500+1000), stats.Downloaded)
+	assert.Equal(t, int64(100+50+100), stats.Uploaded)
+	assert.Equal(t, int64(2500), stats.AlltimeDownloaded)
+	assert.Equal(t, int64(250), stats.AlltimeUploaded)
+
+	stats = sampler.Stats(time.Minute)
+	assert.Equal(t, 2, stats.Samples)
+	assert.Equal(t, start.Add(3*time.Minute), stats.From)
+	assert.Equal(t, int64(1000), stats.Downloaded)
+}
+
+func TestServerStateSampler_Sample(t *testing.T) {
+	responses := map[string]string{
+		"0": `{"rid":1,"full_update":true,"server_state":{"dl_info_speed":10,"global_ratio":"0.50"}}`,
+		"1": `{"rid":2,"torrents":{"a":{"progress":1}},"server_state":{"dl_info_speed":20}}`,
+	}
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(responses[r.URL.Query().Get("rid")]))
+		},
+	})
+
+	sampler := NewServerStateSampler(client, SamplerOptions{})
+	assert.NoError(t, sampler.Sample(context.Background()))
+	assert.NoError(t, sampler.Sample(context.Background()))
+
+	samples := sampler.Samples(time.Time{})
+	assert.Len(t, samples, 2)
+	assert.Equal(t, int64(20), samples[1].ServerState.DlInfoSpeed)
+	assert.Equal(t, 0.5, samples[1].ServerState.GlobalRatio)
+}
+
+func TestServerStateSampler_RunRetries(t *testing.T) {
+	setForTest(t, &MaxPollBackoff, 20*time.Millisecond)
+
+	var polls atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			// qBittorrent restarting
+			if polls.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"server_state":{"dl_info_speed":10}}`))
+		},
+	})
+
+	sampler := NewServerStateSampler(client, SamplerOptions{Interval: 10 * time.Millisecond})
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- sampler.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, ok := sampler.Latest()
+		return ok
+	}, 4*time.Second, 5*time.Millisecond)
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestServerStateSampler_SampleCache(t *testing.T) {
+	var polls atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			polls.Add(1)
+			_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"server_state":{"dl_info_speed":10}}`))
+		},
+	})
+
+	cache := NewSyncCache(client, SyncCacheOptions{})
+	sampler := NewServerStateSampler(client, SamplerOptions{Cache: cache})
+
+	// nothing to sample before the cache synced
+	assert.NoError(t, sampler.Sample(context.Background()))
+	assert.Empty(t, sampler.Samples(time.Time{}))
+
+	assert.NoError(t, cache.Sync(context.Background()))
+	assert.NoError(t, sampler.Sample(context.Background()))
+
+	latest, ok := sampler.Latest()
+	assert.True(t, ok)
+	assert.Equal(t, int64(10), latest.ServerState.DlInfoSpeed)
+	assert.Equal(t, int32(1), polls.Load())
+}
@@ -0,0 +1,410 @@
+package qbittorrent
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// incompleteSuffix is appended to incomplete files when "Append .!qB extension to
+// incomplete files" is enabled.
+const incompleteSuffix = ".!qB"
+
+// ErrPartialScan is returned by ScanFilesCtx when asked to quarantine orphaned files
+// while only some torrents are scanned.
+var ErrPartialScan = errors.New("cannot quarantine orphaned files of a partial torrent list")
+
+// ErrUnreferencedRoot is returned by ScanFilesCtx when asked to quarantine the orphaned
+// files of a root no torrent has files below, which usually means PathMap is wrong.
+var ErrUnreferencedRoot = errors.New("no torrent has files below root")
+
+type ScanOptions struct {
+	// Roots are the local download directories searched for orphaned files. Without roots
+	// only torrents are checked for missing data.
+	Roots []string
+
+	// PathMap maps path prefixes as qBittorrent reports them to local paths, e.g.
+	// {"/downloads": "/mnt/qbittorrent"} when qBittorrent runs in a container. The
+	// longest matching prefix wins; other paths are used as they are.
+	PathMap map[string]string
+
+	// Ignore holds patterns, see path.Match, of file names never reported as orphaned.
+	Ignore []string
+
+	// MinAge skips orphaned files modified more recently, e.g. those of torrents being
+	// added while scanning.
+	MinAge time.Duration
+
+	// Torrents are checked and their files counted as referenced. If nil, all torrents
+	// of the client are. The files of other torrents are reported as orphaned.
+	Torrents []Torrent
+
+	// QuarantineDir, if set, is where orphaned files are moved to, keeping their path
+	// below the base name of their root. It cannot be used with Torrents, as files of
+	// torrents not listed would be moved, and roots no torrent has files below are
+	// refused.
+	QuarantineDir string
+}
+
+type ScanReport struct {
+	Orphans []OrphanFile
+
+	// Torrents with missing or size mismatched files.
+	Missing []TorrentMissingFiles
+}
+
+// OrphanFile is a file below a root that no torrent references.
+type OrphanFile struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+
+	// QuarantinePath is where the file was moved to, empty if it was not.
+	QuarantinePath string
+}
+
+type TorrentMissingFiles struct {
+	Hash  string
+	Name  string
+	Files []MissingFile
+}
+
+type MissingFile struct {
+	// Path is the local path of the file.
+	Path string
+
+	// Size is the size of the file in the torrent, ActualSize the size on disk, 0 if it
+	// is missing.
+	Size       int64
+	ActualSize int64
+	Missing    bool
+}
+
+// ScanFilesCtx compares the files of torrents with the local filesystem. It reports files
+// below opts.Roots no torrent references, and torrents whose files are missing or have
+// another size. Files that are not downloaded at all are not expected to exist, and only
+// completely downloaded files are expected to have their full size.
+//
+// Roots and the paths of torrents are compared as absolute paths with symlinks resolved,
+// as far as they exist. The part files
+// libtorrent keeps the pieces of skipped files in count as referenced.
+//
+// File lists are requested one torrent at a time, which takes a while on large instances.
+func (c *Client) ScanFilesCtx(ctx context.Context, opts ScanOptions) (*ScanReport, error) {
+	if opts.QuarantineDir != "" && opts.Torrents != nil {
+		return nil, ErrPartialScan
+	}
+
+	torrents := opts.Torrents
+	if torrents == nil {
+		var err error
+		torrents, err = c.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get torrents")
+		}
+	}
+
+	report := &ScanReport{}
+	referenced := map[string]struct{}{}
+
+	for _, t := range torrents {
+		files, err := c.GetFilesInformationCtx(ctx, t.Hash)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get files of %v", t.Hash)
+		}
+
+		base, err := resolvePath(opts.localPath(torrentBasePath(t)))
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get path of %v", t.Hash)
+		}
+
+		for _, name := range partFileNames(t) {
+			referenced[filepath.Join(base, name)] = struct{}{}
+		}
+
+		var missing []MissingFile
+		for _, f := range *files {
+			p := filepath.Join(base, filepath.FromSlash(f.Name))
+
+			referenced[p] = struct{}{}
+			referenced[p+incompleteSuffix] = struct{}{}
+
+			if mf, ok := checkFile(p, f.Size, f.Progress); !ok {
+				missing = append(missing, mf)
+			}
+		}
+
+		if len(missing) > 0 {
+			report.Missing = append(report.Missing, TorrentMissingFiles{Hash: t.Hash, Name: t.Name, Files: missing})
+		}
+	}
+
+	for _, root := range opts.Roots {
+		orphans, err := opts.findOrphans(ctx, root, referenced)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Orphans = append(report.Orphans, orphans...)
+	}
+
+	return report, nil
+}
+
+// torrentBasePath returns the directory the file names of t are relative to: its download
+// path while it is stored there, its save path otherwise.
+func torrentBasePath(t Torrent) string {
+	if t.DownloadPath != "" && strings.HasPrefix(t.ContentPath, t.DownloadPath) {
+		return t.DownloadPath
+	}
+
+	return t.SavePath
+}
+
+// partFileNames returns the possible names of the part file of t, ".<infohash>.parts".
+// libtorrent names it after the v2 infohash, truncated to the length of a v1 one, if the
+// torrent has one, which is not the hash qBittorrent identifies hybrid torrents by.
+func partFileNames(t Torrent) []string {
+	var names []string
+	for _, hash := range []string{t.Hash, t.InfohashV1, t.InfohashV2} {
+		if len(hash) > 40 {
+			hash = hash[:40]
+		}
+		if hash != "" {
+			names = append(names, "."+strings.ToLower(hash)+".parts")
+		}
+	}
+
+	return names
+}
+
+func (o ScanOptions) localPath(p string) string {
+	return mapLocalPath(o.PathMap, p)
+}
+
+// mapLocalPath maps p, as qBittorrent reports it, to a local path by the longest matching
+// prefix of pathMap.
+func mapLocalPath(pathMap map[string]string, p string) string {
+	p = filepath.ToSlash(p)
+
+	var from, to string
+	for prefix, local := range pathMap {
+		prefix = strings.TrimRight(filepath.ToSlash(prefix), "/")
+		if (p == prefix || strings.HasPrefix(p, prefix+"/")) && len(prefix) >= len(from) {
+			from, to = prefix, local
+		}
+	}
+
+	if from != "" || to != "" {
+		p = path.Join(filepath.ToSlash(to), strings.TrimPrefix(p, from))
+	}
+
+	return filepath.FromSlash(p)
+}
+
+func checkFile(p string, size int64, progress float32) (MissingFile, bool) {
+	if progress <= 0 {
+		return MissingFile{}, true
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		// incomplete files may carry the suffix
+		if progress < 1 {
+			if _, err := os.Stat(p + incompleteSuffix); err == nil {
+				return MissingFile{}, true
+			}
+		}
+
+		return MissingFile{Path: p, Size: size, Missing: true}, false
+	}
+
+	if progress >= 1 && fi.Size() != size {
+		return MissingFile{Path: p, Size: size, ActualSize: fi.Size()}, false
+	}
+
+	return MissingFile{}, true
+}
+
+func (o ScanOptions) findOrphans(ctx context.Context, root string, referenced map[string]struct{}) ([]OrphanFile, error) {
+	var orphans []OrphanFile
+
+	root, err := resolvePath(root)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan %v", root)
+	}
+
+	quarantineDir := ""
+	if o.QuarantineDir != "" {
+		if quarantineDir, err = resolvePath(o.QuarantineDir); err != nil {
+			return nil, errors.Wrap(err, "could not get path of %v", o.QuarantineDir)
+		}
+
+		// with paths that do not line up, every file would be moved
+		if !containsReferenced(root, referenced) {
+			return nil, errors.Wrap(ErrUnreferencedRoot, "could not quarantine %v", root)
+		}
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// do not search what was quarantined before
+			if p == quarantineDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if _, ok := referenced[p]; ok || o.ignored(d.Name()) {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if o.MinAge > 0 && time.Since(fi.ModTime()) < o.MinAge {
+			return nil
+		}
+
+		orphans = append(orphans, OrphanFile{Path: p, Size: fi.Size(), ModTime: fi.ModTime()})
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not scan %v", root)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].Path < orphans[j].Path
+	})
+
+	if quarantineDir == "" {
+		return orphans, nil
+	}
+
+	for i, orphan := range orphans {
+		rel, err := filepath.Rel(root, orphan.Path)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not quarantine %v", orphan.Path)
+		}
+
+		dest := filepath.Join(quarantineDir, filepath.Base(root), rel)
+		if err := moveFile(orphan.Path, dest); err != nil {
+			return nil, errors.Wrap(err, "could not quarantine %v", orphan.Path)
+		}
+
+		orphans[i].QuarantinePath = dest
+	}
+
+	return orphans, nil
+}
+
+// resolvePath returns the absolute path of p with symlinks resolved, as far as p exists.
+func resolvePath(p string) (string, error) {
+	p, err := filepath.Abs(p)
+	if err != nil {
+		return "", err
+	}
+
+	rest := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(p)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(p)
+		if parent == p {
+			return filepath.Join(p, rest), nil
+		}
+
+		rest = filepath.Join(filepath.Base(p), rest)
+		p = parent
+	}
+}
+
+// containsReferenced reports whether a referenced path is below root.
+func containsReferenced(root string, referenced map[string]struct{}) bool {
+	prefix := strings.TrimSuffix(root, string(filepath.Separator)) + string(filepath.Separator)
+	for p := range referenced {
+		if strings.HasPrefix(p, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (o ScanOptions) ignored(name string) bool {
+	for _, pattern := range o.Ignore {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// moveFile renames src to dest, copying it if they are on different filesystems. An
+// existing dest is not overwritten.
+func moveFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(dest); err == nil {
+		return errors.New("%v already exists", dest)
+	}
+
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,163 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFile(t *testing.T, p string, size int) {
+	t.Helper()
+
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	require.NoError(t, os.WriteFile(p, make([]byte, size), 0o644))
+}
+
+func TestClient_ScanFilesCtx(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "downloads")
+
+	writeTestFile(t, filepath.Join(root, "tv", "Show", "e01.mkv"), 10)
+	writeTestFile(t, filepath.Join(root, "tv", "Show", "e02.mkv"), 15) // should be 20
+	writeTestFile(t, filepath.Join(root, "movies", "Movie.mkv"), 50)
+	writeTestFile(t, filepath.Join(root, "incomplete", "Partial", "a.bin.!qB"), 5)
+	writeTestFile(t, filepath.Join(root, "incomplete", ".partial.parts"), 5)
+	writeTestFile(t, filepath.Join(root, "movies", "Old.mkv"), 7)
+	writeTestFile(t, filepath.Join(root, "tv", "Show", "sample.nfo"), 1)
+	writeTestFile(t, filepath.Join(root, "movies", "Thumbs.db"), 1)
+	writeTestFile(t, filepath.Join(root, "new.mkv"), 3)
+
+	old := time.Now().Add(-time.Hour)
+	for _, p := range []string{"movies/Old.mkv", "tv/Show/sample.nfo", "movies/Thumbs.db", "incomplete/.partial.parts"} {
+		require.NoError(t, os.Chtimes(filepath.Join(root, p), old, old))
+	}
+
+	files := map[string]string{
+		"show":    `[{"name":"Show/e01.mkv","size":10,"progress":1},{"name":"Show/e02.mkv","size":20,"progress":1}]`,
+		"movie":   `[{"name":"Movie.mkv","size":50,"progress":1}]`,
+		"gone":    `[{"name":"Gone.mkv","size":10,"progress":1}]`,
+		"partial": `[{"name":"Partial/a.bin","size":10,"progress":0.5},{"name":"Partial/b.bin","size":10,"progress":0}]`,
+	}
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[
+				{"hash":"show","name":"Show","save_path":"/data/tv","content_path":"/data/tv/Show"},
+				{"hash":"movie","name":"Movie.mkv","save_path":"/data/movies","content_path":"/data/movies/Movie.mkv"},
+				{"hash":"gone","name":"Gone.mkv","save_path":"/data/movies","content_path":"/data/movies/Gone.mkv"},
+				{"hash":"partial","name":"Partial","save_path":"/data/movies","download_path":"/data/incomplete","content_path":"/data/incomplete/Partial"}
+			]`))
+		},
+		"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(files[r.FormValue("hash")]))
+		},
+	})
+
+	// relative paths are compared as absolute ones
+	t.Chdir(dir)
+
+	opts := ScanOptions{
+		Roots:   []string{"downloads/"},
+		PathMap: map[string]string{"/data": "./downloads", "/dat": "/wrong"},
+		Ignore:  []string{"Thumbs.db"},
+		MinAge:  time.Minute,
+	}
+
+	report, err := client.ScanFilesCtx(context.Background(), opts)
+	require.NoError(t, err)
+
+	var orphans []string
+	for _, o := range report.Orphans {
+		orphans = append(orphans, o.Path)
+		assert.Empty(t, o.QuarantinePath)
+	}
+	assert.Equal(t, []string{filepath.Join(root, "movies", "Old.mkv"), filepath.Join(root, "tv", "Show", "sample.nfo")}, orphans)
+
+	assert.Equal(t, []TorrentMissingFiles{
+		{Hash: "show", Name: "Show", Files: []MissingFile{{Path: filepath.Join(root, "tv", "Show", "e02.mkv"), Size: 20, ActualSize: 15}}},
+		{Hash: "gone", Name: "Gone.mkv", Files: []MissingFile{{Path: filepath.Join(root, "movies", "Gone.mkv"), Size: 10, Missing: true}}},
+	}, report.Missing)
+
+	// quarantine moves them, a second scan finds nothing
+	opts.QuarantineDir = filepath.Join("downloads", "quarantine")
+
+	report, err = client.ScanFilesCtx(context.Background(), opts)
+	require.NoError(t, err)
+	require.Len(t, report.Orphans, 2)
+
+	for _, o := range report.Orphans {
+		assert.NoFileExists(t, o.Path)
+		assert.FileExists(t, o.QuarantinePath)
+	}
+	assert.Equal(t, filepath.Join(root, "quarantine", "downloads", "movies", "Old.mkv"), report.Orphans[0].QuarantinePath)
+
+	report, err = client.ScanFilesCtx(context.Background(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, report.Orphans)
+}
+
+func TestClient_ScanFilesCtx_QuarantinePartial(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "Other.mkv"), 1)
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"name":"Movie.mkv","size":1,"progress":1}]`))
+		},
+	})
+
+	_, err := client.ScanFilesCtx(context.Background(), ScanOptions{
+		Roots:         []string{root},
+		Torrents:      []Torrent{{Hash: "movie", SavePath: root}},
+		QuarantineDir: filepath.Join(root, "quarantine"),
+	})
+	assert.ErrorIs(t, err, ErrPartialScan)
+	assert.FileExists(t, filepath.Join(root, "Other.mkv"))
+}
+
+func TestClient_ScanFilesCtx_QuarantinePaths(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "downloads")
+	writeTestFile(t, filepath.Join(root, "Movie.mkv"), 1)
+	writeTestFile(t, filepath.Join(root, "Old.mkv"), 1)
+
+	// the root is reached through a symlink, qBittorrent reports the real path
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(root, link))
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"hash":"movie","name":"Movie.mkv","save_path":"/data","content_path":"/data/Movie.mkv"}]`))
+		},
+		"torrents/files": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"name":"Movie.mkv","size":1,"progress":1}]`))
+		},
+	})
+
+	// nothing lines up with a wrong path map, so nothing is moved
+	_, err := client.ScanFilesCtx(context.Background(), ScanOptions{
+		Roots:         []string{link},
+		PathMap:       map[string]string{"/data": filepath.Join(dir, "elsewhere")},
+		QuarantineDir: filepath.Join(dir, "quarantine"),
+	})
+	assert.ErrorIs(t, err, ErrUnreferencedRoot)
+	assert.FileExists(t, filepath.Join(root, "Movie.mkv"))
+	assert.FileExists(t, filepath.Join(root, "Old.mkv"))
+
+	report, err := client.ScanFilesCtx(context.Background(), ScanOptions{
+		Roots:         []string{link},
+		PathMap:       map[string]string{"/data": root},
+		QuarantineDir: filepath.Join(dir, "quarantine"),
+	})
+	require.NoError(t, err)
+	require.Len(t, report.Orphans, 1)
+	assert.Equal(t, filepath.Join(root, "Old.mkv"), report.Orphans[0].Path)
+	assert.FileExists(t, filepath.Join(root, "Movie.mkv"))
+	assert.FileExists(t, filepath.Join(dir, "quarantine", "downloads", "Old.mkv"))
+}
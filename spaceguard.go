@@ -0,0 +1,280 @@
+package qbittorrent
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+	"github.com/autobrr/go-qbittorrent/metainfo"
+)
+
+// ErrInsufficientSpace is returned by SpaceGuard when a torrent would not fit on disk.
+var ErrInsufficientSpace = errors.New("not enough free space on disk")
+
+var (
+	// DefaultSpaceGuardInterval is how often SpaceGuard checks by default.
+	DefaultSpaceGuardInterval = time.Minute
+
+	// DefaultSpaceGuardTag marks the torrents SpaceGuard stopped.
+	DefaultSpaceGuardTag = "low-space"
+
+	// DefaultSpaceGuardForcedTag marks the torrents SpaceGuard stopped that were force
+	// started.
+	DefaultSpaceGuardForcedTag = "low-space-forced"
+)
+
+type SpaceGuardOrder int
+
+const (
+	// SpaceGuardOrderPriority restarts torrents by queue position, then oldest first.
+	SpaceGuardOrderPriority SpaceGuardOrder = iota
+
+	// SpaceGuardOrderAdded restarts the oldest torrents first.
+	SpaceGuardOrderAdded
+)
+
+type SpaceGuardOptions struct {
+	// MinFreeSpace in bytes. Below it downloading torrents are stopped, and adds that
+	// would leave less are refused.
+	MinFreeSpace int64
+
+	// ResumeFreeSpace in bytes, from which stopped torrents are restarted. Defaults to
+	// MinFreeSpace; set it higher so torrents are not stopped again right away.
+	ResumeFreeSpace int64
+
+	// Interval between checks. Defaults to DefaultSpaceGuardInterval.
+	Interval time.Duration
+
+	// Tag marks stopped torrents. Defaults to DefaultSpaceGuardTag.
+	Tag string
+
+	// ForcedTag additionally marks stopped torrents that were force started, so they are
+	// force started again. Defaults to DefaultSpaceGuardForcedTag.
+	ForcedTag string
+
+	// Order in which stopped torrents are restarted.
+	Order SpaceGuardOrder
+}
+
+// SpaceGuardResult is what a check did.
+type SpaceGuardResult struct {
+	FreeSpace int64
+	Stopped   []string
+	Started   []string
+}
+
+// SpaceGuard stops downloads before the disk of the default save path fills up, and
+// restarts them once there is space again. Stopped torrents are tagged, so only those are
+// restarted, also after the guard itself restarts.
+type SpaceGuard struct {
+	client *Client
+	opts   SpaceGuardOptions
+}
+
+func NewSpaceGuard(c *Client, opts SpaceGuardOptions) *SpaceGuard {
+	if opts.ResumeFreeSpace < opts.MinFreeSpace {
+		opts.ResumeFreeSpace = opts.MinFreeSpace
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultSpaceGuardInterval
+	}
+
+	if opts.Tag == "" {
+		opts.Tag = DefaultSpaceGuardTag
+	}
+
+	if opts.ForcedTag == "" {
+		opts.ForcedTag = DefaultSpaceGuardForcedTag
+	}
+
+	return &SpaceGuard{client: c, opts: opts}
+}
+
+// Run checks until ctx is done or logging in fails. Failed checks are logged and retried
+// with a growing delay, up to MaxPollBackoff.
+func (g *SpaceGuard) Run(ctx context.Context) error {
+	interval := func() time.Duration { return g.opts.Interval }
+
+	return g.client.pollLoop(ctx, "space guard check", interval, func(ctx context.Context) error {
+		_, err := g.Check(ctx)
+		return err
+	})
+}
+
+// Check gets the free space once. Below MinFreeSpace it stops and tags all downloading
+// torrents: stopping a torrent frees no space, it only keeps the torrent from using more,
+// so stopping some would not be enough. From ResumeFreeSpace it restarts the tagged
+// torrents, in order, as long as what they have left to download leaves MinFreeSpace
+// free. Torrents that were force started are force started again.
+func (g *SpaceGuard) Check(ctx context.Context) (SpaceGuardResult, error) {
+	free, err := g.client.GetFreeSpaceOnDiskCtx(ctx)
+	if err != nil {
+		return SpaceGuardResult{}, errors.Wrap(err, "could not get free space")
+	}
+
+	res := SpaceGuardResult{FreeSpace: free}
+
+	switch {
+	case free < g.opts.MinFreeSpace:
+		torrents, err := g.client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+		if err != nil {
+			return res, errors.Wrap(err, "could not get torrents")
+		}
+
+		var forced []string
+		for _, t := range torrents {
+			if isDownloading(t.State) {
+				res.Stopped = append(res.Stopped, t.Hash)
+			}
+			if t.State == TorrentStateForcedDl {
+				forced = append(forced, t.Hash)
+			}
+		}
+
+		if len(res.Stopped) == 0 {
+			return res, nil
+		}
+
+		// tag first, so the torrents are restarted even if stopping fails halfway
+		if len(forced) > 0 {
+			if err := g.client.AddTagsCtx(ctx, forced, g.opts.ForcedTag); err != nil {
+				return res, errors.Wrap(err, "could not tag forced torrents")
+			}
+		}
+
+		if err := g.client.AddTagsCtx(ctx, res.Stopped, g.opts.Tag); err != nil {
+			return res, errors.Wrap(err, "could not tag torrents")
+		}
+
+		if err := g.client.StopCtx(ctx, res.Stopped); err != nil {
+			return res, errors.Wrap(err, "could not stop torrents")
+		}
+	case free >= g.opts.ResumeFreeSpace:
+		torrents, err := g.client.GetTorrentsCtx(ctx, TorrentFilterOptions{Tag: g.opts.Tag})
+		if err != nil {
+			return res, errors.Wrap(err, "could not get torrents")
+		}
+
+		g.sort(torrents)
+
+		var forced []string
+		left := free
+		for _, t := range torrents {
+			if left-t.AmountLeft < g.opts.MinFreeSpace {
+				continue
+			}
+			left -= t.AmountLeft
+
+			res.Started = append(res.Started, t.Hash)
+			if slices.Contains(splitTags(t.Tags), g.opts.ForcedTag) {
+				forced = append(forced, t.Hash)
+			}
+		}
+
+		if len(res.Started) == 0 {
+			return res, nil
+		}
+
+		if err := g.client.StartCtx(ctx, res.Started); err != nil {
+			return res, errors.Wrap(err, "could not start torrents")
+		}
+
+		if len(forced) > 0 {
+			if err := g.client.SetForceStartCtx(ctx, forced, true); err != nil {
+				return res, errors.Wrap(err, "could not force start torrents")
+			}
+
+			if err := g.client.RemoveTagsCtx(ctx, forced, g.opts.ForcedTag); err != nil {
+				return res, errors.Wrap(err, "could not untag forced torrents")
+			}
+		}
+
+		if err := g.client.RemoveTagsCtx(ctx, res.Started, g.opts.Tag); err != nil {
+			return res, errors.Wrap(err, "could not untag torrents")
+		}
+	}
+
+	return res, nil
+}
+
+func (g *SpaceGuard) sort(torrents []Torrent) {
+	sort.SliceStable(torrents, func(i, j int) bool {
+		a, b := torrents[i], torrents[j]
+
+		// queue positions start at 1, 0 means queueing is disabled
+		if g.opts.Order == SpaceGuardOrderPriority && a.Priority != b.Priority {
+			if a.Priority <= 0 || b.Priority <= 0 {
+				return a.Priority > 0
+			}
+			return a.Priority < b.Priority
+		}
+
+		return a.AddedOn < b.AddedOn
+	})
+}
+
+// isDownloading reports whether a torrent in state is downloading or about to.
+func isDownloading(state TorrentState) bool {
+	switch state {
+	case TorrentStateDownloading, TorrentStateMetaDl, TorrentStateQueuedDl, TorrentStateStalledDl,
+		TorrentStateForcedDl, TorrentStateAllocating:
+		return true
+	}
+
+	return false
+}
+
+// CheckTorrent returns ErrInsufficientSpace if the torrent in buf, with what running
+// downloads have left, would leave less than MinFreeSpace free.
+func (g *SpaceGuard) CheckTorrent(ctx context.Context, buf []byte) error {
+	mi, err := metainfo.ParseTorrent(buf)
+	if err != nil {
+		return errors.Wrap(err, "could not parse torrent")
+	}
+
+	free, err := g.client.GetFreeSpaceOnDiskCtx(ctx)
+	if err != nil {
+		return errors.Wrap(err, "could not get free space")
+	}
+
+	torrents, err := g.client.GetTorrentsCtx(ctx, TorrentFilterOptions{})
+	if err != nil {
+		return errors.Wrap(err, "could not get torrents")
+	}
+
+	var pending int64
+	for _, t := range torrents {
+		if isDownloading(t.State) {
+			pending += t.AmountLeft
+		}
+	}
+
+	if left := free - pending - mi.TotalSize(); left < g.opts.MinFreeSpace {
+		return errors.Wrap(ErrInsufficientSpace, "%v needs %d bytes, %d bytes free, %d bytes pending", mi.Name(), mi.TotalSize(), free, pending)
+	}
+
+	return nil
+}
+
+// AddTorrentFromMemoryCtx adds the torrent in buf unless CheckTorrent refuses it.
+func (g *SpaceGuard) AddTorrentFromMemoryCtx(ctx context.Context, buf []byte, options map[string]string) error {
+	if err := g.CheckTorrent(ctx, buf); err != nil {
+		return err
+	}
+
+	return g.client.AddTorrentFromMemoryCtx(ctx, buf, options)
+}
+
+// AddTorrentFromFileCtx adds the torrent file at filePath unless CheckTorrent refuses it.
+func (g *SpaceGuard) AddTorrentFromFileCtx(ctx context.Context, filePath string, options map[string]string) error {
+	buf, err := os.ReadFile(filePath)
+	if err != nil {
+		return errors.Wrap(err, "could not read file %v", filePath)
+	}
+
+	return g.AddTorrentFromMemoryCtx(ctx, buf, options)
+}
@@ -0,0 +1,146 @@
+package qbittorrent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spaceGuardTestInstance struct {
+	mu   sync.Mutex
+	free int64
+	callRecorder
+}
+
+func (s *spaceGuardTestInstance) client(t *testing.T) *Client {
+	return newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			_, _ = fmt.Fprintf(w, `{"rid":1,"full_update":true,"server_state":{"free_space_on_disk":%d}}`, s.free)
+		},
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("tag") == "low-space" {
+				_, _ = w.Write([]byte(`[
+					{"hash":"new","state":"stoppedDL","priority":0,"added_on":3,"amount_left":10,"tags":"low-space, low-space-forced"},
+					{"hash":"old","state":"stoppedDL","priority":0,"added_on":1,"amount_left":60},
+					{"hash":"first","state":"stoppedDL","priority":1,"added_on":2,"amount_left":30}
+				]`))
+				return
+			}
+
+			_, _ = w.Write([]byte(`[
+				{"hash":"dl","state":"downloading","amount_left":20},
+				{"hash":"stalled","state":"stalledDL","amount_left":30},
+				{"hash":"forced","state":"forcedDL","amount_left":5},
+				{"hash":"stopped","state":"stoppedDL","amount_left":100},
+				{"hash":"seed","state":"uploading"}
+			]`))
+		},
+		"torrents/addTags":       s.handler("addTags", "tags"),
+		"torrents/removeTags":    s.handler("removeTags", "tags"),
+		"torrents/stop":          s.handler("stop"),
+		"torrents/start":         s.handler("start"),
+		"torrents/setForceStart": s.handler("setForceStart", "value"),
+		"torrents/add":           s.handler("add"),
+	})
+}
+
+func TestSpaceGuard_Check(t *testing.T) {
+	instance := &spaceGuardTestInstance{free: 50}
+	guard := NewSpaceGuard(instance.client(t), SpaceGuardOptions{MinFreeSpace: 100, ResumeFreeSpace: 150})
+
+	res, err := guard.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SpaceGuardResult{FreeSpace: 50, Stopped: []string{"dl", "stalled", "forced"}}, res)
+	assert.Equal(t, []string{"addTags forced low-space-forced", "addTags dl|stalled|forced low-space", "stop dl|stalled|forced"}, instance.get())
+
+	// between the thresholds nothing happens
+	instance.free = 120
+	res, err = guard.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SpaceGuardResult{FreeSpace: 120}, res)
+	assert.Empty(t, instance.get())
+
+	// by queue position, then oldest first, as long as 100 bytes stay free: old does not fit
+	instance.free = 150
+	res, err = guard.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, SpaceGuardResult{FreeSpace: 150, Started: []string{"first", "new"}}, res)
+	assert.Equal(t, []string{"start first|new", "setForceStart new true", "removeTags new low-space-forced", "removeTags first|new low-space"}, instance.get())
+
+	guard = NewSpaceGuard(guard.client, SpaceGuardOptions{MinFreeSpace: 100, Order: SpaceGuardOrderAdded})
+	res, err = guard.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "new"}, res.Started)
+
+	instance.free = 200
+	res, err = guard.Check(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old", "first", "new"}, res.Started)
+}
+
+func TestSpaceGuard_CheckTorrent(t *testing.T) {
+	instance := &spaceGuardTestInstance{free: 205}
+	client := instance.client(t)
+
+	guard := NewSpaceGuard(client, SpaceGuardOptions{MinFreeSpace: 100})
+
+	// 55 bytes pending of running downloads
+	buf := buildTorrent(t, "Fits", crossSeedFile{path: "Fits", size: 50})
+	assert.NoError(t, guard.AddTorrentFromMemoryCtx(context.Background(), buf, nil))
+	assert.Equal(t, []string{"add "}, instance.get())
+
+	buf = buildTorrent(t, "Big", crossSeedFile{path: "Big/a", size: 40}, crossSeedFile{path: "Big/b", size: 20})
+	err := guard.AddTorrentFromMemoryCtx(context.Background(), buf, nil)
+	assert.ErrorIs(t, err, ErrInsufficientSpace)
+	assert.ErrorContains(t, err, "Big needs 60 bytes, 205 bytes free, 55 bytes pending")
+	assert.Empty(t, instance.get())
+
+	assert.ErrorContains(t, guard.CheckTorrent(context.Background(), []byte("nope")), "could not parse torrent")
+}
+
+func TestSpaceGuard_RunRetries(t *testing.T) {
+	setForTest(t, &MaxPollBackoff, 20*time.Millisecond)
+
+	var checks atomic.Int32
+	retried := make(chan struct{})
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			// qBittorrent restarting
+			switch checks.Add(1) {
+			case 1, 2:
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			case 3:
+				close(retried)
+			}
+			_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"server_state":{"free_space_on_disk":100}}`))
+		},
+	})
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewSpaceGuard(client, SpaceGuardOptions{Interval: 10 * time.Millisecond}).Run(ctx)
+	}()
+
+	select {
+	case <-retried:
+	case err := <-done:
+		t.Fatalf("Run returned: %v", err)
+	}
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
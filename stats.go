@@ -0,0 +1,127 @@
+package qbittorrent
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the request latency histogram in ClientStats.
+// Changing it only affects endpoints first requested afterwards, see EndpointStats.Bounds.
+var LatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// ClientStats are counters of the requests a Client made.
+type ClientStats struct {
+	// Endpoints is keyed by WebAPI endpoint, e.g. "torrents/info".
+	Endpoints map[string]EndpointStats
+
+	// Retries counts requests that were attempted again after a failure.
+	Retries int64
+
+	// Relogins counts logins caused by the session expiring during a request.
+	Relogins int64
+}
+
+type EndpointStats struct {
+	Requests int64
+	Errors   int64
+
+	// Duration is the total time spent, including retries.
+	Duration time.Duration
+
+	// Buckets counts the requests that took at most the matching Bounds entry. Counts
+	// are cumulative, requests slower than every bound are only in Requests.
+	Buckets []int64
+
+	// Bounds are the LatencyBuckets at the time of the first request to the endpoint.
+	Bounds []time.Duration
+}
+
+type clientStats struct {
+	mu    sync.Mutex
+	stats ClientStats
+}
+
+// Stats returns the counters of the requests made so far.
+func (c *Client) Stats() ClientStats {
+	c.stats.mu.Lock()
+	defer c.stats.mu.Unlock()
+
+	stats := c.stats.stats
+	stats.Endpoints = make(map[string]EndpointStats, len(c.stats.stats.Endpoints))
+	for endpoint, e := range c.stats.stats.Endpoints {
+		e.Buckets = append([]int64(nil), e.Buckets...)
+		e.Bounds = append([]time.Duration(nil), e.Bounds...)
+		stats.Endpoints[endpoint] = e
+	}
+
+	return stats
+}
+
+func (s *clientStats) request(req *http.Request, d time.Duration, err error) {
+	endpoint := requestEndpoint(req)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stats.Endpoints == nil {
+		s.stats.Endpoints = make(map[string]EndpointStats)
+	}
+
+	e := s.stats.Endpoints[endpoint]
+	if e.Buckets == nil {
+		e.Bounds = append([]time.Duration(nil), LatencyBuckets...)
+		e.Buckets = make([]int64, len(e.Bounds))
+	}
+
+	e.Requests++
+	if err != nil {
+		e.Errors++
+	}
+
+	e.Duration += d
+	for i, bound := range e.Bounds {
+		if d <= bound {
+			e.Buckets[i]++
+		}
+	}
+
+	s.stats.Endpoints[endpoint] = e
+}
+
+func (s *clientStats) retry() {
+	s.mu.Lock()
+	s.stats.Retries++
+	s.mu.Unlock()
+}
+
+func (s *clientStats) relogin() {
+	s.mu.Lock()
+	s.stats.Relogins++
+	s.mu.Unlock()
+}
+
+// requestEndpoint returns the WebAPI endpoint of req, e.g. "torrents/info".
+func requestEndpoint(req *http.Request) string {
+	const apiBase = "/api/v2/"
+
+	path := req.URL.Path
+	if i := strings.Index(path, apiBase); i >= 0 {
+		return path[i+len(apiBase):]
+	}
+
+	return path
+}
@@ -0,0 +1,56 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient_Stats(t *testing.T) {
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[]`))
+		},
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err := client.GetTorrentsCtx(context.Background(), TorrentFilterOptions{})
+		assert.NoError(t, err)
+	}
+
+	stats := client.Stats()
+	e := stats.Endpoints["torrents/info"]
+	assert.Equal(t, int64(3), e.Requests)
+	assert.Equal(t, int64(0), e.Errors)
+	assert.Len(t, e.Buckets, len(LatencyBuckets))
+	assert.Equal(t, int64(3), e.Buckets[len(e.Buckets)-1])
+	assert.Equal(t, int64(0), stats.Retries)
+	assert.Equal(t, int64(0), stats.Relogins)
+
+	// the returned stats are a copy
+	e.Buckets[0] = 100
+	assert.NotEqual(t, int64(100), client.Stats().Endpoints["torrents/info"].Buckets[0])
+}
+
+func TestClient_Stats_Login(t *testing.T) {
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"auth/login": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("Ok."))
+		},
+	})
+	client.cfg.Username = "admin"
+
+	assert.NoError(t, client.LoginCtx(context.Background()))
+
+	// endpoints keep the bounds they started with
+	setForTest(t, &LatencyBuckets, append(LatencyBuckets, time.Minute))
+	assert.NoError(t, client.LoginCtx(context.Background()))
+
+	e := client.Stats().Endpoints["auth/login"]
+	assert.Equal(t, int64(2), e.Requests)
+	assert.Len(t, e.Bounds, len(LatencyBuckets)-1)
+	assert.Len(t, e.Buckets, len(e.Bounds))
+}
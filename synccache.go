@@ -0,0 +1,299 @@
+package qbittorrent
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+type SyncCacheOptions struct {
+	// Interval between syncs. Defaults to the refresh interval reported by the server.
+	Interval time.Duration
+
+	// StatePath, if set, is loaded when Run starts and saved when it returns, see
+	// SaveStateFile.
+	StatePath string
+}
+
+// SyncCache keeps a MainData in sync with the server and shares it between goroutines.
+// Run owns the sync loop; readers use Snapshot and the lookups, which never call the
+// WebAPI. Lookups are answered from indexes that are updated with each delta.
+type SyncCache struct {
+	client *Client
+	opts   SyncCacheOptions
+
+	// syncMu serializes Sync, so concurrent calls do not request and apply the same
+	// delta twice
+	syncMu sync.Mutex
+
+	mu       sync.RWMutex
+	data     MainData
+	index    *torrentIndex
+	onResync []func()
+}
+
+func NewSyncCache(c *Client, opts SyncCacheOptions) *SyncCache {
+	return &SyncCache{
+		client: c,
+		opts:   opts,
+		index:  newTorrentIndex(),
+	}
+}
+
+// OnResync registers fn to be called after the server answered with a full update
+// although the cache already had state, e.g. because qBittorrent restarted. The state has
+// been replaced by then; fn is called from the goroutine syncing.
+func (s *SyncCache) OnResync(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onResync = append(s.onResync, fn)
+}
+
+// Run syncs until ctx is done or logging in fails. Failed syncs are logged and retried
+// with a growing delay, up to MaxPollBackoff, so a restart of qBittorrent is followed by
+// a resync rather than ending Run.
+func (s *SyncCache) Run(ctx context.Context) error {
+	if s.opts.StatePath != "" {
+		if err := s.LoadStateFile(s.opts.StatePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			// a state that cannot be used only costs a full update
+			s.client.log.Warn("could not load sync state", "path", s.opts.StatePath, "error", err)
+		}
+
+		defer func() {
+			if err := s.SaveStateFile(s.opts.StatePath); err != nil {
+				s.client.log.Warn("could not save sync state", "path", s.opts.StatePath, "error", err)
+			}
+		}()
+	}
+
+	return s.client.pollLoop(ctx, "sync", s.interval, s.Sync)
+}
+
+// Sync requests the changes since the previous sync once and applies them. Concurrent
+// calls are made one after the other.
+func (s *SyncCache) Sync(ctx context.Context) error {
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+
+	s.mu.RLock()
+	rid := s.data.Rid
+	s.mu.RUnlock()
+
+	// readers are not blocked while the request is made
+	delta, err := s.client.syncMainDataDeltaCtx(ctx, rid)
+	if err != nil {
+		return errors.Wrap(err, "could not sync main data")
+	}
+
+	s.mu.Lock()
+	resync := delta.FullUpdate && s.data.Rid != 0
+
+	if err := s.data.apply(delta); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+
+	s.index.update(&s.data, delta)
+	handlers := append([]func(){}, s.onResync...)
+	s.mu.Unlock()
+
+	if resync {
+		for _, fn := range handlers {
+			fn()
+		}
+	}
+
+	return nil
+}
+
+func (s *SyncCache) interval() time.Duration {
+	if s.opts.Interval > 0 {
+		return s.opts.Interval
+	}
+
+	s.mu.RLock()
+	ms := s.data.ServerState.RefreshInterval
+	s.mu.RUnlock()
+
+	if ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	return DefaultWatchInterval
+}
+
+// Snapshot returns a copy of the current state that is safe to keep and modify.
+func (s *SyncCache) Snapshot() MainData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.clone()
+}
+
+// Torrent returns the torrent with the given hash.
+func (s *SyncCache) Torrent(hash string) (Torrent, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t, ok := s.data.Torrents[hash]
+	if !ok {
+		return Torrent{}, false
+	}
+
+	return t.clone(), true
+}
+
+// ServerState returns the current server state.
+func (s *SyncCache) ServerState() ServerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.ServerState
+}
+
+// syncedServerState is ServerState, which is false before the first sync.
+func (s *SyncCache) syncedServerState() (ServerState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.ServerState, s.data.Rid != 0
+}
+
+// Categories returns the categories keyed by name.
+func (s *SyncCache) Categories() map[string]Category {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return maps.Clone(s.data.Categories)
+}
+
+// TorrentByInfohash returns the torrent with the given v1 or v2 infohash.
+func (s *SyncCache) TorrentByInfohash(infohash string) (Torrent, bool) {
+	torrents := s.lookup(indexInfohash, infohash)
+	if len(torrents) == 0 {
+		return Torrent{}, false
+	}
+
+	return torrents[0], true
+}
+
+// Torrents returns all torrents sorted by hash.
+func (s *SyncCache) Torrents() []Torrent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	torrents := make([]Torrent, 0, len(s.data.Torrents))
+	for _, t := range s.data.Torrents {
+		torrents = append(torrents, t.clone())
+	}
+
+	sort.Slice(torrents, func(i, j int) bool {
+		return torrents[i].Hash < torrents[j].Hash
+	})
+
+	return torrents
+}
+
+// ByCategory returns the torrents in category, sorted by hash. An empty category returns
+// the uncategorized torrents.
+func (s *SyncCache) ByCategory(category string) []Torrent {
+	return s.lookup(indexCategory, category)
+}
+
+// ByTag returns the torrents tagged with tag, sorted by hash.
+func (s *SyncCache) ByTag(tag string) []Torrent {
+	return s.lookup(indexTag, tag)
+}
+
+// ByTracker returns the torrents announcing to the tracker url, sorted by hash.
+func (s *SyncCache) ByTracker(tracker string) []Torrent {
+	return s.lookup(indexTrackerURL, tracker)
+}
+
+// ByTrackerHost returns the torrents announcing to any tracker url on host, sorted by
+// hash. The port is not part of host.
+func (s *SyncCache) ByTrackerHost(host string) []Torrent {
+	return s.lookup(indexTrackerHost, host)
+}
+
+// ByContentPath returns the torrents with the given content path, sorted by hash.
+func (s *SyncCache) ByContentPath(path string) []Torrent {
+	return s.lookup(indexContentPath, path)
+}
+
+// ByName returns the torrents named name, sorted by hash.
+func (s *SyncCache) ByName(name string) []Torrent {
+	return s.lookup(indexName, name)
+}
+
+func (s *SyncCache) lookup(kind indexKind, key string) []Torrent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hashes := s.index.lookup(kind, key)
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	torrents := make([]Torrent, 0, len(hashes))
+	for _, hash := range hashes {
+		if t, ok := s.data.Torrents[hash]; ok {
+			torrents = append(torrents, t.clone())
+		}
+	}
+
+	return torrents
+}
+
+// clone copies d so that neither copy shares maps or slices with the other.
+func (d *MainData) clone() MainData {
+	c := *d
+
+	c.Torrents = make(map[string]Torrent, len(d.Torrents))
+	for hash, t := range d.Torrents {
+		c.Torrents[hash] = t.clone()
+	}
+
+	c.Categories = maps.Clone(d.Categories)
+
+	c.Trackers = make(map[string][]string, len(d.Trackers))
+	for tracker, hashes := range d.Trackers {
+		c.Trackers[tracker] = slices.Clone(hashes)
+	}
+
+	c.TorrentsRemoved = slices.Clone(d.TorrentsRemoved)
+	c.CategoriesRemoved = slices.Clone(d.CategoriesRemoved)
+	c.Tags = slices.Clone(d.Tags)
+	c.TagsRemoved = slices.Clone(d.TagsRemoved)
+	c.TrackersRemoved = slices.Clone(d.TrackersRemoved)
+
+	return c
+}
+
+func (t Torrent) clone() Torrent {
+	t.Trackers = slices.Clone(t.Trackers)
+	return t
+}
+
+// splitTags splits the comma separated tags of a torrent.
+func splitTags(tags string) []string {
+	if tags == "" {
+		return nil
+	}
+
+	s := strings.Split(tags, ",")
+	for i := range s {
+		s[i] = strings.TrimSpace(s[i])
+	}
+
+	return s
+}
@@ -0,0 +1,198 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncCache(t *testing.T) {
+	responses := map[string]string{
+		"0": `{"rid":1,"full_update":true,
+			"torrents":{
+				"a":{"name":"a","category":"tv","tags":"x, y","tracker":"http://t1/announce"},
+				"b":{"name":"b","category":"movies","tags":"y"},
+				"c":{"name":"c"}
+			},
+			"trackers":{"http://t1/announce":["a"],"http://t2/announce":["a","b"]}}`,
+		"1": `{"rid":2,"torrents":{"c":{"category":"tv"}},"torrents_removed":["b"]}`,
+	}
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(responses[r.URL.Query().Get("rid")]))
+		},
+	})
+
+	cache := NewSyncCache(client, SyncCacheOptions{})
+	ctx := context.Background()
+
+	assert.NoError(t, cache.Sync(ctx))
+
+	a, ok := cache.Torrent("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", a.Name)
+
+	_, ok = cache.Torrent("missing")
+	assert.False(t, ok)
+
+	assert.Equal(t, []string{"a"}, torrentHashes(cache.ByCategory("tv")))
+	assert.Equal(t, []string{"c"}, torrentHashes(cache.ByCategory("")))
+	assert.Equal(t, []string{"a", "b"}, torrentHashes(cache.ByTag("y")))
+	assert.Equal(t, []string{"a"}, torrentHashes(cache.ByTag("x")))
+	assert.Equal(t, []string{"a"}, torrentHashes(cache.ByTracker("http://t1/announce")))
+	assert.Equal(t, []string{"a", "b"}, torrentHashes(cache.ByTracker("http://t2/announce")))
+	assert.Equal(t, []string{"a", "b"}, torrentHashes(cache.ByTrackerHost("t2")))
+	assert.Equal(t, []string{"b"}, torrentHashes(cache.ByName("b")))
+
+	byInfohash, ok := cache.TorrentByInfohash("A")
+	assert.True(t, ok)
+	assert.Equal(t, "a", byInfohash.Hash)
+
+	snapshot := cache.Snapshot()
+	delete(snapshot.Torrents, "a")
+	_, ok = cache.Torrent("a")
+	assert.True(t, ok, "modifying a snapshot must not change the cache")
+
+	assert.NoError(t, cache.Sync(ctx))
+	assert.Equal(t, []string{"a", "c"}, torrentHashes(cache.ByCategory("tv")))
+	assert.Len(t, cache.Torrents(), 2)
+
+	// the earlier snapshot is unaffected by the sync
+	assert.Contains(t, snapshot.Torrents, "b")
+	assert.Equal(t, "", snapshot.Torrents["c"].Category)
+}
+
+func TestSyncCache_ConcurrentReaders(t *testing.T) {
+	rid := 0
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			rid++
+			if rid == 1 {
+				_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"torrents":{"a":{"progress":0}}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"rid":` + strconv.Itoa(rid) + `,"torrents":{"a":{"progress":0.5}}}`))
+		},
+	})
+
+	cache := NewSyncCache(client, SyncCacheOptions{})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cache.Snapshot()
+				cache.Torrent("a")
+				cache.ByCategory("")
+			}
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, cache.Sync(ctx))
+	}
+
+	wg.Wait()
+}
+
+func torrentHashes(torrents []Torrent) []string {
+	hashes := make([]string, 0, len(torrents))
+	for _, t := range torrents {
+		hashes = append(hashes, t.Hash)
+	}
+
+	return hashes
+}
+
+func TestSyncCache_ConcurrentSync(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		rids []string
+	)
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			rid := r.URL.Query().Get("rid")
+			rids = append(rids, rid)
+
+			next, _ := strconv.Atoi(rid)
+			_, _ = w.Write([]byte(`{"rid":` + strconv.Itoa(next+1) + `,"full_update":` + strconv.FormatBool(next == 0) + `}`))
+		},
+	})
+
+	cache := NewSyncCache(client, SyncCacheOptions{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, cache.Sync(context.Background()))
+		}()
+	}
+	wg.Wait()
+
+	// each sync continues from the previous one
+	assert.Equal(t, []string{"0", "1", "2", "3"}, rids)
+	assert.Equal(t, int64(4), cache.Snapshot().Rid)
+}
+
+func TestSyncCache_RunRetries(t *testing.T) {
+	setForTest(t, &MaxPollBackoff, 20*time.Millisecond)
+
+	var polls atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			switch polls.Add(1) {
+			case 2, 3:
+				// qBittorrent restarting
+				w.WriteHeader(http.StatusServiceUnavailable)
+			default:
+				// after the restart qBittorrent answers with a full update again
+				_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"torrents":{"a":{}}}`))
+			}
+		},
+	})
+
+	cache := NewSyncCache(client, SyncCacheOptions{Interval: 10 * time.Millisecond})
+
+	resynced := make(chan struct{}, 1)
+	cache.OnResync(func() {
+		select {
+		case resynced <- struct{}{}:
+		default:
+		}
+	})
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cache.Run(ctx)
+	}()
+
+	select {
+	case <-resynced:
+	case err := <-done:
+		t.Fatalf("Run returned: %v", err)
+	}
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.GreaterOrEqual(t, polls.Load(), int32(4))
+}
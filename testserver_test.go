@@ -0,0 +1,74 @@
+package qbittorrent
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// newTestClient starts a fake qBittorrent WebUI reporting the given WebAPI version and
+// returns a client for it. handlers are keyed by endpoint, e.g. "torrents/info".
+func newTestClient(t *testing.T, version string, handlers map[string]http.HandlerFunc) *Client {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/app/webapiVersion", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(version))
+	})
+	for endpoint, handler := range handlers {
+		mux.HandleFunc("/api/v2/"+endpoint, handler)
+	}
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	return NewClient(Config{Host: srv.URL})
+}
+
+// callRecorder records the calls a fake WebUI received, e.g. "stop a|b".
+type callRecorder struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *callRecorder) record(call string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, call)
+}
+
+// handler records endpoint with the posted hashes, followed by the values of fields that
+// are set.
+func (c *callRecorder) handler(endpoint string, fields ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		call := endpoint + " " + r.FormValue("hashes")
+		for _, f := range fields {
+			if v := r.FormValue(f); v != "" {
+				call += " " + v
+			}
+		}
+
+		c.record(call)
+	}
+}
+
+// get returns the calls so far and forgets them.
+func (c *callRecorder) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	calls := c.calls
+	c.calls = nil
+	return calls
+}
+
+// setForTest sets a package level variable, e.g. a poll interval, for the duration of
+// the test.
+func setForTest[T any](t *testing.T, p *T, v T) {
+	t.Helper()
+
+	old := *p
+	*p = v
+	t.Cleanup(func() { *p = old })
+}
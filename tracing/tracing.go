@@ -0,0 +1,103 @@
+// Package tracing creates spans for the requests of a qbittorrent.Client.
+//
+// It does not depend on a tracing library; Tracer and Span are small enough to adapt to
+// OpenTelemetry or any other tracer:
+//
+//	type otelTracer struct{ trace.Tracer }
+//
+//	func (t otelTracer) Start(ctx context.Context, name string) (context.Context, tracing.Span) {
+//		ctx, span := t.Tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+//		return ctx, otelSpan{span}
+//	}
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+// Attribute keys set on spans.
+const (
+	AttributeEndpoint   = "qbittorrent.endpoint"
+	AttributeHashes     = "qbittorrent.hashes"
+	AttributeAttempt    = "qbittorrent.attempt"
+	AttributeMethod     = "http.request.method"
+	AttributeStatusCode = "http.response.status_code"
+)
+
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any, and returns a context
+	// holding the new span.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttribute(key string, value any)
+	RecordError(err error)
+	End()
+}
+
+// Hook returns a RequestHook that creates a span named after the endpoint for each
+// request, with a child span for each attempt. Logins caused by an expired session are
+// children of the request that caused them.
+func Hook(t Tracer) qbittorrent.RequestHook {
+	return hook{tracer: t}
+}
+
+type hook struct {
+	tracer Tracer
+}
+
+type spanKey struct{}
+
+func (h hook) RequestStart(ctx context.Context, info qbittorrent.RequestInfo) context.Context {
+	name := "qbittorrent " + info.Endpoint
+	if info.Attempt > 0 {
+		name += " attempt " + strconv.Itoa(info.Attempt)
+	}
+
+	ctx, span := h.tracer.Start(ctx, name)
+	span.SetAttribute(AttributeEndpoint, info.Endpoint)
+	span.SetAttribute(AttributeMethod, info.Method)
+
+	if info.Hashes != 0 {
+		span.SetAttribute(AttributeHashes, info.Hashes)
+	}
+
+	if info.Attempt > 0 {
+		span.SetAttribute(AttributeAttempt, info.Attempt)
+	}
+
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (h hook) RequestEnd(ctx context.Context, info qbittorrent.RequestInfo, result qbittorrent.RequestResult) {
+	span, ok := ctx.Value(spanKey{}).(Span)
+	if !ok {
+		return
+	}
+
+	if result.StatusCode != 0 {
+		span.SetAttribute(AttributeStatusCode, result.StatusCode)
+	}
+
+	if result.Err != nil {
+		span.RecordError(result.Err)
+	} else if result.StatusCode >= http.StatusBadRequest {
+		span.RecordError(&StatusError{StatusCode: result.StatusCode})
+	}
+
+	span.End()
+}
+
+// StatusError is recorded on spans of requests answered with an error status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return "unexpected status: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+}
@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/autobrr/go-qbittorrent"
+)
+
+type testSpan struct {
+	name   string
+	parent string
+	attrs  map[string]any
+	err    error
+	ended  bool
+}
+
+func (s *testSpan) SetAttribute(key string, value any) { s.attrs[key] = value }
+func (s *testSpan) RecordError(err error)              { s.err = err }
+func (s *testSpan) End()                               { s.ended = true }
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type currentKey struct{}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	span := &testSpan{name: name, attrs: make(map[string]any)}
+	if parent, ok := ctx.Value(currentKey{}).(*testSpan); ok {
+		span.parent = parent.name
+	}
+
+	t.spans = append(t.spans, span)
+	return context.WithValue(ctx, currentKey{}, span), span
+}
+
+func TestHook(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/torrents/delete", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tracer := &testTracer{}
+	client := qbittorrent.NewClient(qbittorrent.Config{
+		Host:  srv.URL,
+		Hooks: []qbittorrent.RequestHook{Hook(tracer)},
+	})
+
+	ctx, root := tracer.Start(context.Background(), "root")
+	_ = client.DeleteTorrentsCtx(ctx, []string{"a", "b", "c"}, false)
+	root.End()
+
+	assert.Len(t, tracer.spans, 3)
+
+	request, attempt := tracer.spans[1], tracer.spans[2]
+	assert.Equal(t, "qbittorrent torrents/delete", request.name)
+	assert.Equal(t, "root", request.parent)
+	assert.Equal(t, "qbittorrent torrents/delete attempt 1", attempt.name)
+	assert.Equal(t, request.name, attempt.parent)
+
+	assert.Equal(t, 3, request.attrs[AttributeHashes])
+	assert.Equal(t, http.MethodPost, request.attrs[AttributeMethod])
+	assert.Equal(t, 1, attempt.attrs[AttributeAttempt])
+	assert.Equal(t, http.StatusNotFound, attempt.attrs[AttributeStatusCode])
+
+	for _, span := range tracer.spans {
+		assert.True(t, span.ended, span.name)
+	}
+
+	var statusErr *StatusError
+	assert.ErrorAs(t, request.err, &statusErr)
+	assert.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+}
@@ -0,0 +1,89 @@
+package qbittorrent
+
+import (
+	"strings"
+)
+
+// TrackerMessageClass is what a tracker message tells about a torrent.
+type TrackerMessageClass string
+
+const (
+	// TrackerMessageOther is any message no words match, including none.
+	TrackerMessageOther TrackerMessageClass = ""
+
+	// TrackerMessageUnregistered means the tracker does not know the torrent, e.g. it was
+	// deleted or trumped.
+	TrackerMessageUnregistered TrackerMessageClass = "unregistered"
+
+	// TrackerMessageAuthFailure means the passkey or account was rejected.
+	TrackerMessageAuthFailure TrackerMessageClass = "auth_failure"
+
+	// TrackerMessageRateLimited means announces are too frequent.
+	TrackerMessageRateLimited TrackerMessageClass = "rate_limited"
+
+	// TrackerMessageDown means the tracker could not be reached or failed to respond.
+	TrackerMessageDown TrackerMessageClass = "down"
+
+	// TrackerMessageTruncated means the tracker response was cut off or malformed.
+	TrackerMessageTruncated TrackerMessageClass = "truncated"
+)
+
+// trackerMessageClasses are tried in order, the first class with a matching word wins.
+var trackerMessageClasses = []TrackerMessageClass{
+	TrackerMessageUnregistered,
+	TrackerMessageAuthFailure,
+	TrackerMessageRateLimited,
+	TrackerMessageDown,
+	TrackerMessageTruncated,
+}
+
+// DefaultTrackerMessageWords are the words TrackerMessageClassifier uses when it has none.
+var DefaultTrackerMessageWords = map[TrackerMessageClass][]string{
+	TrackerMessageUnregistered: {"unregistered", "not registered", "not found", "not exist"},
+	TrackerMessageAuthFailure:  {"passkey", "unauthorized", "not authorized", "authentication", "access denied"},
+	TrackerMessageRateLimited:  {"rate limit", "too many requests", "slow down", "announce interval"},
+	TrackerMessageDown:         {"timed out", "timeout", "connection refused", "unreachable", "bad gateway", "service unavailable", "maintenance"},
+	TrackerMessageTruncated:    {"truncated", "unexpected end", "invalid bencod"},
+}
+
+// TrackerMessageClassifier classifies tracker messages by the words they contain, compared
+// case insensitively. The zero value uses DefaultTrackerMessageWords.
+type TrackerMessageClassifier struct {
+	// Words by class. Classes without words use DefaultTrackerMessageWords; an empty,
+	// non-nil list turns a class off.
+	Words map[TrackerMessageClass][]string
+
+	// Trackers overrides Words by tracker host, e.g. "tracker.example.org". Classes a
+	// tracker has no words for use Words.
+	Trackers map[string]map[TrackerMessageClass][]string
+}
+
+var defaultTrackerMessageClassifier = &TrackerMessageClassifier{}
+
+// Classify returns the class of msg, as sent by the tracker at trackerURL.
+func (c *TrackerMessageClassifier) Classify(trackerURL, msg string) TrackerMessageClass {
+	msg = strings.ToLower(msg)
+	if msg == "" {
+		return TrackerMessageOther
+	}
+
+	overrides := c.Trackers[trackerHost(trackerURL)]
+
+	for _, class := range trackerMessageClasses {
+		words, ok := overrides[class]
+		if !ok {
+			words, ok = c.Words[class]
+		}
+		if !ok {
+			words = DefaultTrackerMessageWords[class]
+		}
+
+		for _, w := range words {
+			if strings.Contains(msg, strings.ToLower(w)) {
+				return class
+			}
+		}
+	}
+
+	return TrackerMessageOther
+}
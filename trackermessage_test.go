@@ -0,0 +1,49 @@
+package qbittorrent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerMessageClassifier_Classify(t *testing.T) {
+	classifier := &TrackerMessageClassifier{
+		Words: map[TrackerMessageClass][]string{
+			TrackerMessageDown: {},
+		},
+		Trackers: map[string]map[TrackerMessageClass][]string{
+			"tracker.example.org": {
+				TrackerMessageUnregistered: {"Trumped"},
+			},
+		},
+	}
+
+	tests := []struct {
+		tracker string
+		msg     string
+		want    TrackerMessageClass
+	}{
+		{msg: "", want: TrackerMessageOther},
+		{msg: "Torrent not registered with this tracker", want: TrackerMessageUnregistered},
+		{msg: "Unregistered torrent", want: TrackerMessageUnregistered},
+		{msg: "Torrent does not exist", want: TrackerMessageUnregistered},
+		{msg: "Invalid passkey", want: TrackerMessageAuthFailure},
+		{msg: "Rate limited, try again later", want: TrackerMessageRateLimited},
+		{msg: "expected value (list, dict, int or string) in bencoded string: truncated", want: TrackerMessageTruncated},
+		// turned off
+		{msg: "Connection timed out", want: TrackerMessageOther},
+		{tracker: "https://tracker.example.org/abc/announce", msg: "Trumped by a better release", want: TrackerMessageUnregistered},
+		{tracker: "https://tracker.example.org/abc/announce", msg: "Torrent not found", want: TrackerMessageOther},
+		{tracker: "https://other.example/announce", msg: "Trumped by a better release", want: TrackerMessageOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.msg, func(t *testing.T) {
+			assert.Equal(t, tt.want, classifier.Classify(tt.tracker, tt.msg))
+		})
+	}
+
+	assert.Equal(t, TrackerMessageDown, (&TrackerMessageClassifier{}).Classify("", "Connection timed out"))
+	assert.True(t, isUnregistered("Torrent Not Found"))
+	assert.False(t, isUnregistered("Invalid passkey"))
+}
@@ -0,0 +1,214 @@
+package qbittorrent
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Masterminds/semver"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// DefaultUnregisteredTag is added by UnregisteredActionTag by default.
+var DefaultUnregisteredTag = "unregistered"
+
+// includeTrackersMinVersion is the first WebAPI version whose torrents/info includes
+// trackers on request.
+var includeTrackersMinVersion = semver.MustParse("2.11.4")
+
+type UnregisteredAction string
+
+const (
+	UnregisteredActionTag  UnregisteredAction = "tag"
+	UnregisteredActionStop UnregisteredAction = "stop"
+
+	// UnregisteredActionDelete deletes torrents, with their files if DeleteFiles is set.
+	UnregisteredActionDelete UnregisteredAction = "delete"
+)
+
+type UnregisteredOptions struct {
+	// Action applied to unregistered torrents. Without one they are only reported.
+	Action UnregisteredAction
+
+	// Tag added by UnregisteredActionTag. Defaults to DefaultUnregisteredTag.
+	Tag string
+
+	DeleteFiles bool
+
+	// Classifier of tracker messages. Defaults to DefaultTrackerMessageWords.
+	Classifier *TrackerMessageClassifier
+
+	// Torrents are checked. If nil, all torrents of the client are. Trackers of torrents
+	// requested with IncludeTrackers are used as they are.
+	Torrents []Torrent
+}
+
+// UnregisteredError holds the torrents whose trackers could not be checked.
+type UnregisteredError struct {
+	// Errors is keyed by hash.
+	Errors map[string]error
+}
+
+func (e *UnregisteredError) Error() string {
+	hashes := make([]string, 0, len(e.Errors))
+	for hash := range e.Errors {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	msgs := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		msgs = append(msgs, e.Errors[hash].Error())
+	}
+
+	return "checking " + strconv.Itoa(len(hashes)) + " torrents failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *UnregisteredError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// UnregisteredTorrent is a torrent a tracker reported as unregistered.
+type UnregisteredTorrent struct {
+	Hash    string
+	Name    string
+	Tracker string
+	Message string
+}
+
+// HandleUnregisteredTorrents finds and handles unregistered torrents, see
+// HandleUnregisteredTorrentsCtx.
+func (c *Client) HandleUnregisteredTorrents(opts UnregisteredOptions) ([]UnregisteredTorrent, error) {
+	return c.HandleUnregisteredTorrentsCtx(context.Background(), opts)
+}
+
+// HandleUnregisteredTorrentsCtx checks the trackers of torrents and applies opts.Action to
+// those a tracker reports as unregistered. Torrents with another tracker that works, or
+// may still work because it was not contacted yet or is updating, are left alone.
+//
+// Trackers are requested one torrent at a time unless the torrents carry them, which
+// they do when got here from servers with WebAPI 2.11.4 or later. Torrents whose trackers
+// cannot be requested are skipped and returned in an *UnregisteredError, after the others
+// were handled.
+func (c *Client) HandleUnregisteredTorrentsCtx(ctx context.Context, opts UnregisteredOptions) ([]UnregisteredTorrent, error) {
+	torrents := opts.Torrents
+	if torrents == nil {
+		includeTrackers, err := c.RequiresMinVersion(includeTrackersMinVersion)
+		if !includeTrackers && !errors.Is(err, ErrUnsupportedVersion) {
+			return nil, err
+		}
+
+		torrents, err = c.GetTorrentsCtx(ctx, TorrentFilterOptions{IncludeTrackers: includeTrackers})
+		if err != nil {
+			return nil, errors.Wrap(err, "could not get torrents")
+		}
+	}
+
+	classifier := opts.Classifier
+	if classifier == nil {
+		classifier = defaultTrackerMessageClassifier
+	}
+
+	var (
+		unregistered []UnregisteredTorrent
+		hashes       []string
+		errs         = map[string]error{}
+	)
+
+	for _, t := range torrents {
+		trackers := t.Trackers
+		if trackers == nil {
+			var err error
+			trackers, err = c.GetTorrentTrackersCtx(ctx, t.Hash)
+			if err != nil {
+				err = errors.Wrap(err, "could not get trackers of %v", t.Hash)
+
+				// the others would fail the same way
+				if ctx.Err() != nil {
+					return nil, err
+				}
+
+				errs[t.Hash] = err
+				continue
+			}
+		}
+
+		if tracker, ok := unregisteredTracker(classifier, trackers); ok {
+			unregistered = append(unregistered, UnregisteredTorrent{
+				Hash:    t.Hash,
+				Name:    t.Name,
+				Tracker: tracker.Url,
+				Message: tracker.Message,
+			})
+			hashes = append(hashes, t.Hash)
+		}
+	}
+
+	var checkErr error
+	if len(errs) > 0 {
+		checkErr = &UnregisteredError{Errors: errs}
+	}
+
+	if len(hashes) == 0 {
+		return unregistered, checkErr
+	}
+
+	var err error
+	switch opts.Action {
+	case "":
+	case UnregisteredActionTag:
+		tag := opts.Tag
+		if tag == "" {
+			tag = DefaultUnregisteredTag
+		}
+		err = c.AddTagsCtx(ctx, hashes, tag)
+	case UnregisteredActionStop:
+		err = c.StopCtx(ctx, hashes)
+	case UnregisteredActionDelete:
+		err = c.DeleteTorrentsCtx(ctx, hashes, opts.DeleteFiles)
+	default:
+		return unregistered, errors.New("unknown action %q", opts.Action)
+	}
+	if err != nil {
+		return unregistered, errors.Wrap(err, "could not %v unregistered torrents", opts.Action)
+	}
+
+	return unregistered, checkErr
+}
+
+// unregisteredTracker returns the tracker reporting the torrent as unregistered, unless
+// another tracker works or may still work.
+func unregisteredTracker(classifier *TrackerMessageClassifier, trackers []TorrentTracker) (TorrentTracker, bool) {
+	var (
+		unregistered TorrentTracker
+		found        bool
+	)
+
+	for _, tracker := range trackers {
+		// skip the DHT, PeX and LSD pseudo trackers
+		if tracker.Status == TrackerStatusDisabled {
+			continue
+		}
+
+		// trackers may report ok with an unregistered message
+		if classifier.Classify(tracker.Url, tracker.Message) == TrackerMessageUnregistered {
+			if !found {
+				unregistered, found = tracker, true
+			}
+			continue
+		}
+
+		if tracker.Status != TrackerStatusNotWorking {
+			return TorrentTracker{}, false
+		}
+	}
+
+	return unregistered, found
+}
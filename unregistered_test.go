@@ -0,0 +1,113 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_HandleUnregisteredTorrentsCtx(t *testing.T) {
+	trackers := map[string]string{
+		// unregistered, only the DHT pseudo tracker besides
+		"gone": `[{"url":"** [DHT] **","status":0},{"url":"https://t1.example/announce","status":4,"msg":"Unregistered torrent"}]`,
+		// reported ok with an unregistered message
+		"ok-unreg": `[{"url":"https://t1.example/announce","status":2,"msg":"torrent not found"}]`,
+		// another tracker still works
+		"other": `[{"url":"https://t1.example/announce","status":4,"msg":"Unregistered torrent"},{"url":"https://t2.example/announce","status":2}]`,
+		// another tracker was not contacted yet
+		"pending": `[{"url":"https://t1.example/announce","status":4,"msg":"Unregistered torrent"},{"url":"https://t2.example/announce","status":1}]`,
+		// down, not unregistered
+		"down": `[{"url":"https://t1.example/announce","status":4,"msg":"Connection timed out"},{"url":"https://t2.example/announce","status":4,"msg":"Invalid passkey"}]`,
+		// both not working, one unregistered
+		"dead": `[{"url":"https://t2.example/announce","status":4,"msg":"timed out"},{"url":"https://t1.example/announce","status":4,"msg":"Torrent not registered"}]`,
+	}
+
+	var calls callRecorder
+
+	client := newTestClient(t, "2.11.0", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			assert.Empty(t, r.FormValue("includeTrackers"))
+			_, _ = w.Write([]byte(`[{"hash":"gone","name":"Gone"},{"hash":"ok-unreg"},{"hash":"other"},{"hash":"pending"},{"hash":"down"},{"hash":"failing"},{"hash":"dead","name":"Dead"}]`))
+		},
+		"torrents/trackers": func(w http.ResponseWriter, r *http.Request) {
+			hash := r.URL.Query().Get("hash")
+			if hash == "failing" {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(trackers[hash]))
+		},
+		"torrents/addTags": calls.handler("addTags", "tags"),
+		"torrents/stop":    calls.handler("stop"),
+		"torrents/delete":  calls.handler("delete", "deleteFiles"),
+	})
+
+	// a torrent whose trackers cannot be requested is skipped
+	checkFailed := func(t *testing.T, err error) {
+		t.Helper()
+
+		var uerr *UnregisteredError
+		require.ErrorAs(t, err, &uerr)
+		assert.Len(t, uerr.Errors, 1)
+		assert.ErrorContains(t, uerr.Errors["failing"], "could not get trackers of failing")
+	}
+
+	found, err := client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{})
+	checkFailed(t, err)
+	assert.Equal(t, []UnregisteredTorrent{
+		{Hash: "gone", Name: "Gone", Tracker: "https://t1.example/announce", Message: "Unregistered torrent"},
+		{Hash: "ok-unreg", Tracker: "https://t1.example/announce", Message: "torrent not found"},
+		{Hash: "dead", Name: "Dead", Tracker: "https://t1.example/announce", Message: "Torrent not registered"},
+	}, found)
+	assert.Empty(t, calls.get())
+
+	_, err = client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{Action: UnregisteredActionTag})
+	checkFailed(t, err)
+	_, err = client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{Action: UnregisteredActionStop})
+	checkFailed(t, err)
+	_, err = client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{Action: UnregisteredActionDelete, DeleteFiles: true})
+	checkFailed(t, err)
+	assert.Equal(t, []string{
+		"addTags gone|ok-unreg|dead unregistered",
+		"stop gone|ok-unreg|dead",
+		"delete gone|ok-unreg|dead true",
+	}, calls.get())
+
+	// trackers carried by the torrents are used, here with a custom classifier
+	found, err = client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{
+		Classifier: &TrackerMessageClassifier{Words: map[TrackerMessageClass][]string{TrackerMessageUnregistered: {"trumped"}}},
+		Torrents: []Torrent{
+			{Hash: "trumped", Trackers: []TorrentTracker{{Url: "https://t1.example/announce", Status: TrackerStatusNotWorking, Message: "Trumped"}}},
+			{Hash: "gone", Trackers: []TorrentTracker{{Url: "https://t1.example/announce", Status: TrackerStatusNotWorking, Message: "Unregistered torrent"}}},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "trumped", found[0].Hash)
+
+	_, err = client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{Action: "pause"})
+	assert.ErrorContains(t, err, `unknown action "pause"`)
+}
+
+func TestClient_HandleUnregisteredTorrentsCtx_IncludeTrackers(t *testing.T) {
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"torrents/info": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "true", r.FormValue("includeTrackers"))
+			_, _ = w.Write([]byte(`[
+				{"hash":"gone","trackers":[{"url":"https://t1.example/announce","status":4,"msg":"Unregistered torrent"}]},
+				{"hash":"fine","trackers":[{"url":"https://t1.example/announce","status":2}]}
+			]`))
+		},
+		"torrents/trackers": func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("trackers of %v requested", r.FormValue("hash"))
+		},
+	})
+
+	found, err := client.HandleUnregisteredTorrentsCtx(context.Background(), UnregisteredOptions{})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "gone", found[0].Hash)
+}
@@ -0,0 +1,490 @@
+package qbittorrent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/autobrr/go-qbittorrent/errors"
+)
+
+// MaxPollBackoff caps the delay before a failed poll of Watcher or SpaceGuard is retried.
+var MaxPollBackoff = time.Minute
+
+// DefaultWatchInterval is used when neither WatcherOptions.Interval nor the server
+// refresh interval are known.
+var DefaultWatchInterval = 1500 * time.Millisecond
+
+type EventType string
+
+const (
+	// EventTorrentAdded sets Hash and Torrent.
+	EventTorrentAdded EventType = "torrent_added"
+
+	// EventTorrentRemoved sets Hash and Torrent to its last known state.
+	EventTorrentRemoved EventType = "torrent_removed"
+
+	// EventStateChanged sets Hash, Torrent, OldState and NewState.
+	EventStateChanged EventType = "state_changed"
+
+	// EventCompleted sets Hash and Torrent once a torrent reaches 100% progress.
+	EventCompleted EventType = "completed"
+
+	// EventCategoryChanged sets Hash, Torrent, Old and New to the category names.
+	EventCategoryChanged EventType = "category_changed"
+
+	// EventTagsChanged sets Hash, Torrent, Old and New to the comma separated tags.
+	EventTagsChanged EventType = "tags_changed"
+
+	// EventTrackerChanged sets Hash, Torrent, Old and New to the current tracker urls.
+	EventTrackerChanged EventType = "tracker_changed"
+
+	// EventCategoryAdded sets Category.
+	EventCategoryAdded EventType = "category_added"
+
+	// EventCategoryRemoved sets Category to its last known value.
+	EventCategoryRemoved EventType = "category_removed"
+
+	// EventTagAdded sets Tag.
+	EventTagAdded EventType = "tag_added"
+
+	// EventTagRemoved sets Tag.
+	EventTagRemoved EventType = "tag_removed"
+
+	// EventServerStateChanged sets ServerState.
+	EventServerStateChanged EventType = "server_state_changed"
+
+	// EventResync is emitted before the events of a full update that replaced existing
+	// state, e.g. because qBittorrent restarted. Sets ServerState.
+	EventResync EventType = "resync"
+)
+
+// Event is a change detected between two sync/maindata responses. Which fields are set
+// depends on Type.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	Hash    string
+	Torrent Torrent
+
+	OldState TorrentState
+	NewState TorrentState
+
+	Old string
+	New string
+
+	Category    Category
+	Tag         string
+	ServerState ServerState
+}
+
+type WatcherOptions struct {
+	// Interval between polls. Defaults to the refresh interval reported by the server.
+	Interval time.Duration
+
+	// EmitInitial emits EventTorrentAdded, EventCategoryAdded and EventTagAdded for
+	// everything present on the first poll.
+	EmitInitial bool
+
+	// BufferSize of channels returned by Subscribe. Defaults to 64.
+	BufferSize int
+}
+
+// Watcher polls sync/maindata and turns the differences between responses into events.
+// Handlers are called, and subscriptions are fed, from the goroutine running Run; a slow
+// consumer delays the next poll.
+type Watcher struct {
+	client *Client
+	opts   WatcherOptions
+
+	mu       sync.Mutex
+	handlers []watchHandler
+	subs     map[*watchSubscription]struct{}
+
+	data MainData
+}
+
+type watchHandler struct {
+	types map[EventType]struct{}
+	fn    func(Event)
+}
+
+type watchSubscription struct {
+	types map[EventType]struct{}
+	ch    chan Event
+}
+
+func NewWatcher(c *Client, opts WatcherOptions) *Watcher {
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 64
+	}
+
+	return &Watcher{
+		client: c,
+		opts:   opts,
+		subs:   make(map[*watchSubscription]struct{}),
+	}
+}
+
+// OnEvent registers fn for the given event types, or for all events if none are given.
+func (w *Watcher) OnEvent(fn func(Event), types ...EventType) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.handlers = append(w.handlers, watchHandler{types: typeSet(types), fn: fn})
+}
+
+// Subscribe returns a channel receiving the given event types, or all events if none are
+// given, and a function to cancel the subscription. The channel is closed on cancel or
+// when Run returns.
+func (w *Watcher) Subscribe(types ...EventType) (<-chan Event, func()) {
+	sub := &watchSubscription{
+		types: typeSet(types),
+		ch:    make(chan Event, w.opts.BufferSize),
+	}
+
+	w.mu.Lock()
+	w.subs[sub] = struct{}{}
+	w.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+
+			if _, ok := w.subs[sub]; ok {
+				delete(w.subs, sub)
+				close(sub.ch)
+			}
+		})
+	}
+
+	return sub.ch, cancel
+}
+
+// Run polls until ctx is done and returns the context error. Failed polls are logged and
+// retried with backoff, see pollLoop; only a failed login ends Run early.
+func (w *Watcher) Run(ctx context.Context) error {
+	defer w.closeSubscriptions()
+
+	return w.client.pollLoop(ctx, "watcher poll", w.interval, w.Poll)
+}
+
+// Poll requests the changes since the previous poll once and dispatches their events.
+func (w *Watcher) Poll(ctx context.Context) error {
+	delta, err := w.client.syncMainDataDeltaCtx(ctx, w.data.Rid)
+	if err != nil {
+		return errors.Wrap(err, "could not sync main data")
+	}
+
+	first := w.data.Rid == 0
+
+	events, err := diffMainData(&w.data, delta, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if first && !w.opts.EmitInitial {
+		return nil
+	}
+
+	for _, e := range events {
+		if err := w.dispatch(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Watcher) interval() time.Duration {
+	if w.opts.Interval > 0 {
+		return w.opts.Interval
+	}
+
+	if ms := w.data.ServerState.RefreshInterval; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+
+	return DefaultWatchInterval
+}
+
+func (w *Watcher) dispatch(ctx context.Context, e Event) error {
+	w.mu.Lock()
+	handlers := append([]watchHandler(nil), w.handlers...)
+	subs := make([]*watchSubscription, 0, len(w.subs))
+	for sub := range w.subs {
+		subs = append(subs, sub)
+	}
+	w.mu.Unlock()
+
+	for _, h := range handlers {
+		if wants(h.types, e.Type) {
+			h.fn(e)
+		}
+	}
+
+	for _, sub := range subs {
+		if !wants(sub.types, e.Type) {
+			continue
+		}
+
+		if err := w.send(ctx, sub, e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// send delivers e unless the subscription was cancelled in the meantime.
+func (w *Watcher) send(ctx context.Context, sub *watchSubscription, e Event) error {
+	for {
+		w.mu.Lock()
+		if _, ok := w.subs[sub]; !ok {
+			w.mu.Unlock()
+			return nil
+		}
+
+		select {
+		case sub.ch <- e:
+			w.mu.Unlock()
+			return nil
+		default:
+		}
+		w.mu.Unlock()
+
+		// the buffer is full, wait for the consumer without holding the lock
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func (w *Watcher) closeSubscriptions() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for sub := range w.subs {
+		close(sub.ch)
+		delete(w.subs, sub)
+	}
+}
+
+func typeSet(types []EventType) map[EventType]struct{} {
+	if len(types) == 0 {
+		return nil
+	}
+
+	set := make(map[EventType]struct{}, len(types))
+	for _, t := range types {
+		set[t] = struct{}{}
+	}
+
+	return set
+}
+
+func wants(types map[EventType]struct{}, t EventType) bool {
+	if types == nil {
+		return true
+	}
+
+	_, ok := types[t]
+	return ok
+}
+
+// diffMainData applies delta to dest and returns the events describing the change.
+func diffMainData(dest *MainData, delta *mainDataDelta, now time.Time) ([]Event, error) {
+	var (
+		prevTorrents   map[string]Torrent
+		prevCategories map[string]Category
+		prevTags       = make(map[string]struct{}, len(dest.Tags))
+		prevServer     = dest.ServerState
+	)
+
+	for _, tag := range dest.Tags {
+		prevTags[tag] = struct{}{}
+	}
+
+	if delta.FullUpdate {
+		// apply replaces the maps, keep the old ones to compare against
+		prevTorrents = dest.Torrents
+		prevCategories = dest.Categories
+	} else {
+		prevTorrents = make(map[string]Torrent, len(delta.Torrents)+len(delta.TorrentsRemoved))
+		for hash := range delta.Torrents {
+			if t, ok := dest.Torrents[hash]; ok {
+				prevTorrents[hash] = t
+			}
+		}
+		for _, hash := range delta.TorrentsRemoved {
+			if t, ok := dest.Torrents[hash]; ok {
+				prevTorrents[hash] = t
+			}
+		}
+
+		prevCategories = make(map[string]Category, len(delta.Categories)+len(delta.CategoriesRemoved))
+		for name := range delta.Categories {
+			if cat, ok := dest.Categories[name]; ok {
+				prevCategories[name] = cat
+			}
+		}
+		for _, name := range delta.CategoriesRemoved {
+			if cat, ok := dest.Categories[name]; ok {
+				prevCategories[name] = cat
+			}
+		}
+	}
+
+	resync := delta.FullUpdate && dest.Rid != 0
+
+	if err := dest.apply(delta); err != nil {
+		return nil, err
+	}
+
+	var events []Event
+
+	if resync {
+		events = append(events, Event{Type: EventResync, Time: now, ServerState: dest.ServerState})
+	}
+
+	// torrents
+	changed := make([]string, 0, len(delta.Torrents))
+	for hash := range delta.Torrents {
+		changed = append(changed, hash)
+	}
+
+	for _, hash := range changed {
+		cur, ok := dest.Torrents[hash]
+		if !ok {
+			continue
+		}
+
+		prev, existed := prevTorrents[hash]
+		if !existed {
+			events = append(events, Event{Type: EventTorrentAdded, Time: now, Hash: hash, Torrent: cur})
+			continue
+		}
+
+		events = append(events, torrentEvents(hash, prev, cur, now)...)
+	}
+
+	removed := delta.TorrentsRemoved
+	if delta.FullUpdate {
+		removed = nil
+		for hash := range prevTorrents {
+			if _, ok := dest.Torrents[hash]; !ok {
+				removed = append(removed, hash)
+			}
+		}
+	}
+
+	for _, hash := range removed {
+		if prev, ok := prevTorrents[hash]; ok {
+			events = append(events, Event{Type: EventTorrentRemoved, Time: now, Hash: hash, Torrent: prev})
+		}
+	}
+
+	// categories
+	for name, cat := range dest.Categories {
+		if _, ok := delta.Categories[name]; !ok && !delta.FullUpdate {
+			continue
+		}
+
+		if _, existed := prevCategories[name]; !existed {
+			events = append(events, Event{Type: EventCategoryAdded, Time: now, Category: cat})
+		}
+	}
+
+	for name, cat := range prevCategories {
+		if _, ok := dest.Categories[name]; !ok {
+			events = append(events, Event{Type: EventCategoryRemoved, Time: now, Category: cat})
+		}
+	}
+
+	// tags
+	curTags := make(map[string]struct{}, len(dest.Tags))
+	for _, tag := range dest.Tags {
+		curTags[tag] = struct{}{}
+		if _, ok := prevTags[tag]; !ok {
+			events = append(events, Event{Type: EventTagAdded, Time: now, Tag: tag})
+		}
+	}
+
+	for tag := range prevTags {
+		if _, ok := curTags[tag]; !ok {
+			events = append(events, Event{Type: EventTagRemoved, Time: now, Tag: tag})
+		}
+	}
+
+	if dest.ServerState != prevServer {
+		events = append(events, Event{Type: EventServerStateChanged, Time: now, ServerState: dest.ServerState})
+	}
+
+	return events, nil
+}
+
+func torrentEvents(hash string, prev, cur Torrent, now time.Time) []Event {
+	var events []Event
+
+	if prev.State != cur.State {
+		events = append(events, Event{Type: EventStateChanged, Time: now, Hash: hash, Torrent: cur, OldState: prev.State, NewState: cur.State})
+	}
+
+	if prev.Progress < 1 && cur.Progress >= 1 {
+		events = append(events, Event{Type: EventCompleted, Time: now, Hash: hash, Torrent: cur})
+	}
+
+	if prev.Category != cur.Category {
+		events = append(events, Event{Type: EventCategoryChanged, Time: now, Hash: hash, Torrent: cur, Old: prev.Category, New: cur.Category})
+	}
+
+	if prev.Tags != cur.Tags {
+		events = append(events, Event{Type: EventTagsChanged, Time: now, Hash: hash, Torrent: cur, Old: prev.Tags, New: cur.Tags})
+	}
+
+	if prev.Tracker != cur.Tracker {
+		events = append(events, Event{Type: EventTrackerChanged, Time: now, Hash: hash, Torrent: cur, Old: prev.Tracker, New: cur.Tracker})
+	}
+
+	return events
+}
+
+// pollLoop calls poll every interval until ctx is done. A failed poll is logged and
+// retried after a delay that starts at interval and doubles up to MaxPollBackoff, so a
+// restart of qBittorrent does not end the loop. Only ErrLoginFailed, which retrying does
+// not fix, is returned before ctx is done.
+func (c *Client) pollLoop(ctx context.Context, name string, interval func() time.Duration, poll func(context.Context) error) error {
+	var backoff time.Duration
+
+	for {
+		wait := interval()
+
+		if err := poll(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			if errors.Is(err, ErrLoginFailed) {
+				return err
+			}
+
+			backoff = max(min(2*backoff, MaxPollBackoff), wait)
+			wait = backoff
+
+			c.log.Warn(name+" failed, retrying", "delay", wait, "error", err)
+		} else {
+			backoff = 0
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package qbittorrent
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventTypes(events []Event) []EventType {
+	types := make([]EventType, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
+
+func TestDiffMainData(t *testing.T) {
+	now := time.Now()
+	data := MainData{}
+
+	events, err := diffMainData(&data, decodeDelta(t, `{"rid":1,"full_update":true,
+		"torrents":{"a":{"name":"a","state":"downloading","progress":0.5,"category":"tv","tracker":"http://t1"}},
+		"categories":{"tv":{"name":"tv"}},"tags":["x"]}`), now)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []EventType{EventTorrentAdded, EventCategoryAdded, EventTagAdded}, eventTypes(events))
+
+	events, err = diffMainData(&data, decodeDelta(t, `{"rid":2,
+		"torrents":{"a":{"state":"uploading","progress":1,"category":"movies","tags":"x","tracker":"http://t2"}},
+		"categories":{"movies":{"name":"movies"}},"categories_removed":["tv"],"tags":["y"],"tags_removed":["x"]}`), now)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []EventType{
+		EventStateChanged, EventCompleted, EventCategoryChanged, EventTagsChanged, EventTrackerChanged,
+		EventCategoryAdded, EventCategoryRemoved, EventTagAdded, EventTagRemoved,
+	}, eventTypes(events))
+
+	for _, e := range events {
+		switch e.Type {
+		case EventStateChanged:
+			assert.Equal(t, TorrentStateDownloading, e.OldState)
+			assert.Equal(t, TorrentStateUploading, e.NewState)
+			assert.Equal(t, "a", e.Torrent.Name)
+		case EventCategoryChanged:
+			assert.Equal(t, "tv", e.Old)
+			assert.Equal(t, "movies", e.New)
+		case EventCategoryRemoved:
+			assert.Equal(t, "tv", e.Category.Name)
+		case EventTagRemoved:
+			assert.Equal(t, "x", e.Tag)
+		}
+	}
+
+	// nothing changed
+	events, err = diffMainData(&data, decodeDelta(t, `{"rid":3}`), now)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	events, err = diffMainData(&data, decodeDelta(t, `{"rid":4,"torrents_removed":["a"],"server_state":{"dl_info_speed":10}}`), now)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []EventType{EventTorrentRemoved, EventServerStateChanged}, eventTypes(events))
+	assert.Equal(t, "a", events[0].Hash)
+}
+
+func TestDiffMainData_FullUpdateResync(t *testing.T) {
+	now := time.Now()
+	data := MainData{
+		Rid:        10,
+		Torrents:   map[string]Torrent{"a": {Hash: "a"}, "b": {Hash: "b", State: TorrentStatePausedDl}},
+		Categories: map[string]Category{"tv": {Name: "tv"}},
+		Tags:       []string{"x"},
+	}
+
+	// qBittorrent restarted and sent everything again
+	events, err := diffMainData(&data, decodeDelta(t, `{"rid":1,"full_update":true,
+		"torrents":{"b":{"state":"downloading"},"c":{}},"categories":{"tv":{"name":"tv"}},"tags":["x"]}`), now)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []EventType{EventResync, EventTorrentRemoved, EventStateChanged, EventTorrentAdded}, eventTypes(events))
+	assert.Equal(t, EventResync, events[0].Type)
+}
+
+func TestWatcher_Subscribe(t *testing.T) {
+	var polls atomic.Int32
+
+	responses := []string{
+		`{"rid":1,"full_update":true,"torrents":{"a":{"hash":"a","state":"downloading","progress":0.5}},"server_state":{"refresh_interval":10}}`,
+		`{"rid":2,"torrents":{"a":{"hash":"a","state":"uploading","progress":1}},"server_state":{"refresh_interval":10}}`,
+	}
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			n := int(polls.Add(1)) - 1
+			if n >= len(responses) {
+				_, _ = w.Write([]byte(`{"rid":3,"server_state":{"refresh_interval":10}}`))
+				return
+			}
+			_, _ = w.Write([]byte(responses[n]))
+		},
+	})
+
+	watcher := NewWatcher(client, WatcherOptions{})
+
+	var handled atomic.Int32
+	watcher.OnEvent(func(e Event) {
+		handled.Add(1)
+	}, EventStateChanged)
+
+	completed, cancel := watcher.Subscribe(EventCompleted)
+	defer cancel()
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	select {
+	case e := <-completed:
+		assert.Equal(t, "a", e.Hash)
+		assert.Equal(t, TorrentStateUploading, e.Torrent.State)
+	case <-ctx.Done():
+		t.Fatal("no completed event received")
+	}
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, int32(1), handled.Load())
+
+	// the subscription is closed once Run returns
+	_, ok := <-completed
+	assert.False(t, ok)
+}
+
+func TestWatcher_RunRetries(t *testing.T) {
+	setForTest(t, &MaxPollBackoff, 20*time.Millisecond)
+
+	var polls atomic.Int32
+
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			// qBittorrent restarting
+			if polls.Add(1) <= 2 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"rid":1,"full_update":true,"torrents":{"a":{"hash":"a"}}}`))
+		},
+	})
+
+	watcher := NewWatcher(client, WatcherOptions{Interval: 10 * time.Millisecond, EmitInitial: true})
+	added, cancel := watcher.Subscribe(EventTorrentAdded)
+	defer cancel()
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- watcher.Run(ctx)
+	}()
+
+	select {
+	case e := <-added:
+		assert.Equal(t, "a", e.Hash)
+	case err := <-done:
+		t.Fatalf("Run returned: %v", err)
+	case <-ctx.Done():
+		t.Fatal("no event received")
+	}
+
+	stop()
+	assert.ErrorIs(t, <-done, context.Canceled)
+	assert.Equal(t, int32(3), polls.Load())
+}
+
+func TestWatcher_RunLoginFailed(t *testing.T) {
+	client := newTestClient(t, "2.11.4", map[string]http.HandlerFunc{
+		"sync/maindata": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		},
+		"auth/login": func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("Fails."))
+		},
+	})
+	client.cfg.Username = "admin"
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+
+	err := NewWatcher(client, WatcherOptions{Interval: 10 * time.Millisecond}).Run(ctx)
+	assert.ErrorIs(t, err, ErrLoginFailed)
+	assert.NoError(t, ctx.Err())
+}